@@ -44,7 +44,7 @@ class ApiClient {
 
   async scripts (): Promise<Script[]> {
     return await axios({
-      method: 'post',
+      method: 'get',
       url: this.withUrl('scripts')
     }).then(res => res.data)
   }
@@ -9,18 +9,30 @@ export interface Profile {
 export interface Script {
   name: string
   description?: string
-  longDescription?: string
-  version: string
-  configOptions?: ScriptConfigOption[]
+  version?: string
+  author?: string
+  tags?: string[]
+  scope?: string
+  netexVersions?: string[]
+  documentation?: string
+  examples?: ScriptExample[]
+  configSchema?: Record<string, ScriptConfigOption>
   config?: Record<string, any>
 }
 
+export interface ScriptExample {
+  code: string
+  message: string
+  description?: string
+}
+
 export interface ScriptConfigOption {
-  name: string
-  description: string
-  type: string
+  type: 'number' | 'integer' | 'string' | 'boolean' | 'array'
+  description?: string
   default?: any
-  options?: any[]
+  enum?: any[]
+  min?: number
+  max?: number
 }
 
 export interface XSDUploadFile {
@@ -43,5 +55,6 @@ export interface Session {
   xsdFiles?: XSDUpload[]
   status: string
   results: any[]
+  summary?: any
   profile?: Profile
 }
@@ -26,7 +26,7 @@ const ConfigurationInfo = ({
             <Tooltip
               placement="top"
               key={script.name}
-              title={script.longDescription}
+              title={script.documentation}
               disableInteractive
             >
               <Chip
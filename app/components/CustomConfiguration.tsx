@@ -26,11 +26,8 @@ import ChevronRightIcon from '@mui/icons-material/ChevronRight'
 import React from 'react'
 import FileUpload, { type FileList } from './FileUpload'
 import type { Profile, Script, Session, XSDUploadFile } from '../api/types'
-import scriptData from '../public/scripts.json'
 import useApiClient from '../hooks/useApiClient'
 
-const scriptOptions = scriptData.filter(v => v.name !== 'xsd')
-
 export interface CustomConfigurationProps {
   session: Session | null
   onNext: (profile: Profile) => void
@@ -59,7 +56,7 @@ const ScriptRow = ({
   const handleChange = (event: React.ChangeEvent<HTMLInputElement>): void => {
     setChanges({
       ...changes,
-      [event.target.name]: event.target.type === 'number' ? parseInt(event.target.value) : event.target.value
+      [event.target.name]: event.target.type === 'number' ? Number(event.target.value) : event.target.value
     })
   }
 
@@ -76,7 +73,7 @@ const ScriptRow = ({
       <ListItem
         key={script.name}
         secondaryAction={(
-          script.configOptions !== undefined && (
+          Object.keys(script.configSchema ?? {}).length > 0 && (
             <IconButton onClick={() => {
               setOpen(true)
             }}>
@@ -102,7 +99,7 @@ const ScriptRow = ({
           <ListItemText
             id={labelId}
             primary={script.description}
-            secondary={script.longDescription}
+            secondary={script.documentation}
           />
         </Stack>
       </ListItem>
@@ -110,15 +107,15 @@ const ScriptRow = ({
         <DialogTitle>Configuration</DialogTitle>
         <DialogContent>
           <Stack gap={2}>
-            {script.configOptions?.map(opt => (
-              <Stack key={opt.name} gap={1}>
-                <InputLabel>{opt.description}</InputLabel>
+            {Object.entries(script.configSchema ?? {}).map(([name, opt]) => (
+              <Stack key={name} gap={1}>
+                <InputLabel>{opt.description ?? name}</InputLabel>
                 <OutlinedInput
                   placeholder={opt.default?.toString()}
                   size="small"
-                  type={opt.type}
-                  name={opt.name}
-                  value={changes[opt.name] ?? ''}
+                  type={opt.type === 'number' || opt.type === 'integer' ? 'number' : 'text'}
+                  name={name}
+                  value={changes[name] ?? ''}
                   onChange={handleChange}
                 />
               </Stack>
@@ -140,11 +137,13 @@ const CustomConfiguration = ({
 }: CustomConfigurationProps): JSX.Element => {
   const [schema, setSchema] = React.useState<string>('netex@1.2-nc')
   const [schemaEntry, setSchemaEntry] = React.useState<string>('')
-  const [scripts, setScripts] = React.useState<string[]>(scriptOptions.map(v => v.name))
+  const [scriptData, setScriptData] = React.useState<Script[]>([])
+  const [scripts, setScripts] = React.useState<string[]>([])
   const [scriptOpts, setScriptOpts] = React.useState<Record<string, Record<string, any>>>({})
   const [fileList, setFileList] = React.useState<Record<string, unknown>>({})
   const [schemaFiles, setSchemaFiles] = React.useState<XSDUploadFile[]>([])
   const apiClient = useApiClient()
+  const scriptOptions = scriptData.filter(v => v.name !== 'xsd')
 
   const handleSelectSchema = (event: SelectChangeEvent): void => {
     if (event.target?.name === '') {
@@ -200,17 +199,25 @@ const CustomConfiguration = ({
       longDescription: '',
       scripts: [
         xsdScript as Script,
-        ...scripts.map(name => {
-          const script = scriptData.find(v => v.name === name) as Script
-
-          script.config = scriptOpts[script.name]
-
-          return script
-        })
+        ...scripts.map(name => ({
+          ...scriptData.find(v => v.name === name) as Script,
+          config: scriptOpts[name]
+        }))
       ]
     })
   }
 
+  React.useEffect(() => {
+    apiClient.scripts()
+      .then(scripts => {
+        setScriptData(scripts)
+        setScripts(scripts.filter(v => v.name !== 'xsd').map(v => v.name))
+      })
+      .catch(() => {
+        setScriptData([])
+      })
+  }, [apiClient])
+
   React.useEffect(() => {
     if (session == null) {
       return
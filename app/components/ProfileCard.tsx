@@ -31,7 +31,7 @@ const ProfileScriptTable = (props: ProfileScriptTableProps): JSX.Element => {
             <Tooltip
               placement="top"
               key={script.name}
-              title={script.longDescription}
+              title={script.documentation}
               disableInteractive
             >
               <Chip
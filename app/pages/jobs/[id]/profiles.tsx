@@ -11,15 +11,6 @@ import ProfileCard from '../../../components/ProfileCard'
 import ValidationStepper from '../../../components/ValidationStepper'
 import useApiClient from '../../../hooks/useApiClient'
 import profileOptions from '../../../public/profiles.json'
-import scriptOptions from '../../../public/scripts.json'
-
-const profiles = profileOptions.map(v => ({
-  ...v,
-  scripts: v.scripts.map(s => ({
-    ...scriptOptions.find((v) => v.name === s.name),
-    ...s
-  }))
-})) as Profile[]
 
 const Profiles: NextPage = () => {
   const [session, setSession] = React.useState<Session | null>(null)
@@ -27,6 +18,7 @@ const Profiles: NextPage = () => {
   const [errorOpen, setErrorOpen] = React.useState<boolean>(false)
   const [loading, setLoading] = React.useState<boolean>(true)
   const [disabled, setDisabled] = React.useState<boolean>(false)
+  const [profiles, setProfiles] = React.useState<Profile[]>(profileOptions as Profile[])
   const router = useRouter()
   const apiClient = useApiClient()
 
@@ -67,6 +59,22 @@ const Profiles: NextPage = () => {
     })
   }
 
+  React.useEffect(() => {
+    apiClient.scripts()
+      .then(scriptOptions => {
+        setProfiles(profileOptions.map(v => ({
+          ...v,
+          scripts: v.scripts.map(s => ({
+            ...scriptOptions.find((v) => v.name === s.name),
+            ...s
+          }))
+        })) as Profile[])
+      })
+      .catch(err => {
+        setErrorMessage(err.message)
+      })
+  }, [apiClient])
+
   React.useEffect(() => {
     setDisabled(session?.status !== 'created')
   }, [session, setDisabled])
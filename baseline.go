@@ -0,0 +1,121 @@
+package greenlight
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const baselineVersion = "1"
+
+// BaselineFinding is a known finding, matched on its fingerprint
+type BaselineFinding struct {
+	Fingerprint string `json:"fingerprint"`
+	Rule        string `json:"rule"`
+	Document    string `json:"document"`
+	Message     string `json:"message"`
+	Line        int    `json:"line,omitempty"`
+}
+
+// Baseline is a set of known findings to suppress in validation results
+type Baseline struct {
+	Version  string            `json:"version"`
+	Findings []BaselineFinding `json:"findings"`
+}
+
+// NewBaseline creates a baseline of every finding in results
+func NewBaseline(results []*ValidationResult) *Baseline {
+	b := &Baseline{
+		Version:  baselineVersion,
+		Findings: []BaselineFinding{},
+	}
+
+	for _, r := range results {
+		for _, rv := range r.ValidationRules {
+			for _, err := range rv.Errors {
+				b.Findings = append(b.Findings, BaselineFinding{
+					Fingerprint: Fingerprint(rv.Name, r.Name, err),
+					Rule:        rv.Name,
+					Document:    r.Name,
+					Message:     normalizeMessage(err.Message),
+					Line:        err.Line,
+				})
+			}
+		}
+	}
+
+	return b
+}
+
+// OpenBaseline reads a baseline previously written with Save
+func OpenBaseline(path string) (*Baseline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b := &Baseline{}
+	if err := json.NewDecoder(f).Decode(b); err != nil {
+		return nil, err
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf("unsupported baseline version '%s'", b.Version)
+	}
+
+	return b, nil
+}
+
+// Save writes the baseline as json to path
+func (b *Baseline) Save(path string) error {
+	buf, err := json.MarshalIndent(b, "", " ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf, 0o644)
+}
+
+// Apply removes every known finding from results, returning the number of
+// suppressed findings. Each finding of the baseline suppresses at most one
+// finding in the results.
+func (b *Baseline) Apply(results []*ValidationResult) int {
+	known := map[string]int{}
+	for _, f := range b.Findings {
+		known[f.Fingerprint]++
+	}
+
+	total := 0
+	for _, r := range results {
+		for _, rv := range r.ValidationRules {
+			removed := rv.suppress(func(err TaskError) bool {
+				fp := Fingerprint(rv.Name, r.Name, err)
+				if known[fp] > 0 {
+					known[fp]--
+					return true
+				}
+				return false
+			})
+			total += len(removed)
+		}
+		r.revalidate()
+	}
+
+	return total
+}
+
+// Fingerprint identifies a finding by rule, document, type and message. The
+// line is left out so that the fingerprint survives unrelated edits of the
+// document.
+func Fingerprint(rule, document string, err TaskError) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s", rule, document, err.Type, normalizeMessage(err.Message))
+
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func normalizeMessage(msg string) string {
+	return strings.Join(strings.Fields(msg), " ")
+}
@@ -4,6 +4,17 @@
  * @author Concrete IT
  */
 const name = "everyScheduledStopPointHasAName";
+const description = "Every scheduled stop has a name";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["names"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure every <ScheduledStopPoint /> has a <Name /> or <ShortName />.";
+const examples = [
+  { code: "NETEX-NAME-001", message: "Missing name for ScheduledStopPoint(@id=SE:005:ScheduledStopPoint:9022005000023017)" },
+  { code: "NETEX-ID-001", message: "StopPoint is missing attribute @id" },
+];
 const errors = require("errors");
 const types = require("types");
 const xpath = require("xpath");
@@ -34,7 +45,7 @@ function main(ctx) {
       if (!id) {
         res.push(errors.ConsistencyError(
           `StopPoint is missing attribute @id`,
-          { line: node.line() },
+          { node, code: "NETEX-ID-001", params: { type: "ScheduledStopPoint", expected: "@id" } },
         ));
         return;
       }
@@ -45,7 +56,7 @@ function main(ctx) {
       if (!name && !shortName) {
         res.push(errors.ConsistencyError(
           `Missing name for ScheduledStopPoint(@id=${id})`,
-          { line: node.line() },
+          { node, code: "NETEX-NAME-001", params: { type: "ScheduledStopPoint", id, expected: "Name" } },
         ));
       }
 
@@ -4,6 +4,18 @@
  * @author Concrete IT
  */
 const name = "everyStopPlaceHasACorrectStopPlaceType";
+const description = "Every stop place has a stop place type";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["stops"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure every <StopPlace /> has a <StopPlaceType /> and that it is one " +
+  "of the stop place types defined by NeTEx.";
+const examples = [
+  { code: "NETEX-TYPE-001", message: "StopPlaceType is not set for StopPlace(@id=SE:005:StopPlace:9021005000023017)" },
+  { code: "NETEX-TYPE-002", message: "StopPlaceType is not valid for StopPlace(@id=SE:005:StopPlace:9021005000023017)" },
+];
 const errors = require("errors");
 const types = require("types");
 const xpath = require("xpath");
@@ -46,7 +58,7 @@ function main(ctx) {
       if (!stopType) {
         res.push(errors.ConsistencyError(
           `StopPlaceType is not set for StopPlace(@id=${id})`,
-          { line: node.line() },
+          { node, code: "NETEX-TYPE-001", params: { type: "StopPlace", id, expected: "StopPlaceType" } },
         ));
         return res;
       }
@@ -56,7 +68,7 @@ function main(ctx) {
       if (!isItemInSet) {
         res.push(errors.ConsistencyError(
           `StopPlaceType is not valid for StopPlace(@id=${id})`,
-          { line: node.line() },
+          { node, code: "NETEX-TYPE-002", params: { type: "StopPlace", id, expected: [...interestingItems], actual: stopType } },
         ));
       }
 
@@ -4,6 +4,17 @@
  * @author Concrete IT
  */
 const name = "everyStopPlaceHasAName";
+const description = "Every stop place has a name";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["names", "stops"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure every <StopPlace /> has a <Name /> or <ShortName />.";
+const examples = [
+  { code: "NETEX-NAME-001", message: "Missing name for StopPlace(@id=SE:005:StopPlace:9021005000023017)" },
+  { code: "NETEX-ID-001", message: "StopPlace is missing attribute @id" },
+];
 const errors = require("errors");
 const types = require("types");
 const xpath = require("xpath");
@@ -29,7 +40,7 @@ function main(ctx) {
       if (!id) {
         res.push(errors.ConsistencyError(
           `StopPlace is missing attribute @id`,
-          { line: node.line() },
+          { node, code: "NETEX-ID-001", params: { type: "StopPlace", expected: "@id" } },
         ));
         return res;
       }
@@ -40,7 +51,7 @@ function main(ctx) {
       if (!name && !shortName) {
         res.push(errors.ConsistencyError(
           `Missing name for StopPlace(@id=${id})`,
-          { line: node.line() },
+          { node, code: "NETEX-NAME-001", params: { type: "StopPlace", id, expected: "Name" } },
         ));
       }
 
@@ -4,9 +4,22 @@
  * @author Concrete IT
  */
 const name = "everyStopPlaceIsReferenced";
+const description = "Every stop place is referenced";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["references", "stops"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure every <StopPlace /> is referenced from another element, e.g. a " +
+  "<StopPlaceRef /> of a <PassengerStopAssignment />.";
+const examples = [
+  { code: "NETEX-REF-001", message: "Missing reference for StopPlace(@id=SE:005:StopPlace:9021005000023017)" },
+  { code: "NETEX-ID-001", message: "StopPlace is missing attribute @id" },
+];
 const errors = require("errors");
 const types = require("types");
 const xpath = require("xpath");
+const { everyIsReferenced } = require("./lib/references");
 const stopPlacesPath = xpath.join(xpath.path.FRAMES, "SiteFrame", "stopPlaces", "StopPlace");
 
 /**
@@ -15,34 +28,9 @@ const stopPlacesPath = xpath.join(xpath.path.FRAMES, "SiteFrame", "stopPlaces",
  * @return {errors.ScriptError[]?}
  */
 function main(ctx) {
-  ctx.node.find(stopPlacesPath)
-    .getOrElse(() => [])
-    .forEach((n) => ctx.worker.queue("worker", n));
-
-  return ctx.worker.run().get();
-}
-
-function worker(ctx) {
-  const res = [];
-  const id = ctx.node.attr("id").get();
-
-  if (!id) {
-    res.push(errors.ConsistencyError(
-      `StopPlace is missing attribute @id`,
-      { line: ctx.node.line() },
-    ));
-    return res;
-  }
-
-  const stopPlaceRefs = xpath.join("./", `StopPlaceRef[@ref='${id}']`);
-  const refs = ctx.document.find(stopPlaceRefs).get();
-
-  if (!refs || refs.length === 0) {
-    res.push(errors.ConsistencyError(
-      `Missing reference for StopPlace(@id=${id})`,
-      { line: ctx.node.line() },
-    ));
-  }
-
-  return res;
+  return everyIsReferenced(ctx, {
+    type: "StopPlace",
+    path: stopPlacesPath,
+    ref: "StopPlaceRef",
+  });
 }
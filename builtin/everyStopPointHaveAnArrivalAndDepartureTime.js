@@ -4,6 +4,19 @@
  * @author Concrete IT
  */
 const name = "everyStopPointHaveArrivalAndDepartureTime";
+const description = "Every stop point have an arrival and departure time";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["timetable"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure every <TimetabledPassingTime /> of a <ServiceJourney /> has a " +
+  "<DepartureTime />, except at the last stop, and an <ArrivalTime />, except " +
+  "at the first stop.";
+const examples = [
+  { code: "NETEX-TIME-001", message: "Expected departure time in <TimetabledpassingTime id='SE:005:TimetabledPassingTime:55700000063252358' />" },
+  { code: "NETEX-TIME-001", message: "Expected arrival time in <TimetabledpassingTime id='SE:005:TimetabledPassingTime:55700000063252358' />" },
+];
 const errors = require("errors");
 const types = require("types");
 const xpath = require("xpath");
@@ -39,7 +52,6 @@ function worker(ctx) {
   ctx.node.find(passingTimesPath)
     .getOrElse(() => [])
     .forEach((/** @type {types.Node} */ node, i, nodes) => {
-      const line = node.line();
       const id = node.attr("id").get();
       const isFirstElement = i === 0;
       const isLastElement = i === nodes.length - 1;
@@ -47,19 +59,19 @@ function worker(ctx) {
       if (!id) {
         res.push(errors.ConsistencyError(
           `Element <TimetabledpassingTime /> is missing attribute @id`,
-          { line },
+          { node, code: "NETEX-ID-001", params: { type: "TimetabledPassingTime", expected: "@id" } },
         ));
       }
       if (!isLastElement && node.find(departureTimePath).isErr()) {
         res.push(errors.ConsistencyError(
           `Expected departure time in <TimetabledpassingTime id='${id}' />`,
-          { line },
+          { node, code: "NETEX-TIME-001", params: { type: "TimetabledPassingTime", id, expected: "DepartureTime" } },
         ));
       }
       if (!isFirstElement && node.find(arrivalTimePath).isErr()) {
         res.push(errors.ConsistencyError(
           `Expected arrival time in <TimetabledpassingTime id='${id}' />`,
-          { line },
+          { node, code: "NETEX-TIME-001", params: { type: "TimetabledPassingTime", id, expected: "ArrivalTime" } },
         ));
       }
     })
@@ -5,6 +5,19 @@
  */
 // Define a constant variable for the script name
 const name = "frameDefaultsHaveALocaleAndTimeZone";
+const description = "Frame defaults have a locale and timezone";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["frames"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Validate the <DefaultLocale /> of <FrameDefaults />, the <TimeZone /> and " +
+  "<SummerTimeZone /> must be valid time zones, their offsets valid UTC offsets " +
+  "and <DefaultLanguage /> a valid ISO 639-1 language code.";
+const examples = [
+  { code: "NETEX-FRAME-001", message: "Document is missing element <FrameDefaults />" },
+  { code: "NETEX-FRAME-002", message: "Invalid <DefaultLanguage /> in <FrameDefaults />" },
+];
 
 // Import necessary modules
 const errors = require("errors");
@@ -57,7 +70,10 @@ function main(ctx) {
   const node = ctx.node.first(xpath.path.FRAME_DEFAULTS).get();
   // If FrameDefaults element is not found, return a NotFoundError in an array
   if (!node) {
-    return [errors.NotFoundError("Document is missing element <FrameDefaults />")];
+    return [errors.NotFoundError(
+      "Document is missing element <FrameDefaults />",
+      { code: "NETEX-FRAME-001", params: { expected: "FrameDefaults" } },
+    )];
   }
 
   // Validate DefaultLocale and TimeZone elements within FrameDefaults
@@ -68,35 +84,35 @@ function main(ctx) {
       if (!validTimeZoneOffset(node.textAt(tzOffsetPath).get())) {
         res.push(errors.ConsistencyError(
           "Invalid <TimeZoneOffset /> in <FrameDefaults />",
-          { line: node.line() },
+          { node, code: "NETEX-FRAME-002", params: { type: "TimeZoneOffset", actual: node.textAt(tzOffsetPath).get() } },
         ));
       }
       // Validate TimeZone
       if (!validTimeZone(node.textAt(tzPath).get())) {
         res.push(errors.ConsistencyError(
           "Invalid <TimeZone /> in <FrameDefaults />",
-          { line: node.line() },
+          { node, code: "NETEX-FRAME-002", params: { type: "TimeZone", actual: node.textAt(tzPath).get() } },
         ));
       }
       // Validate SummerTimeZoneOffset
       if (!validTimeZoneOffset(node.textAt(stzOffsetPath).get())) {
         res.push(errors.ConsistencyError(
           "Invalid <SummerTimeZoneOffset /> in <FrameDefaults />",
-          { line: node.line() },
+          { node, code: "NETEX-FRAME-002", params: { type: "SummerTimeZoneOffset", actual: node.textAt(stzOffsetPath).get() } },
         ));
       }
       // Validate SummerTimeZone
       if (!validTimeZone(node.textAt(stzPath).get())) {
         res.push(errors.ConsistencyError(
           "Invalid <SummerTimeZone /> in <FrameDefaults />",
-          { line: node.line() },
+          { node, code: "NETEX-FRAME-002", params: { type: "SummerTimeZone", actual: node.textAt(stzPath).get() } },
         ));
       }
       // Validate DefaultLanguage
       if (!validLanguage(node.textAt(defaultLangPath).get())) {
         res.push(errors.ConsistencyError(
           "Invalid <DefaultLanguage /> in <FrameDefaults />",
-          { line: node.line() },
+          { node, code: "NETEX-FRAME-002", params: { type: "DefaultLanguage", actual: node.textAt(defaultLangPath).get() } },
         ));
       }
 
@@ -34,6 +34,21 @@ declare module "types" {
     /** */
     line(): number;
 
+    /** */
+    column(): number;
+
+    /**
+     * Absolute XPath of the node, e.g.
+     * `/PublicationDelivery[1]/dataObjects[1]/CompositeFrame[1]`
+     */
+    path(): string;
+
+    /**
+     * Name of the document the node belongs to, pass it as `extra.document`
+     * when reporting errors from a collection scoped script
+     */
+    documentName(): string;
+
     /** */
     parent(): Result<Node>;
 
@@ -58,12 +73,57 @@ declare module "types" {
   }
 
   /** */
+  /**
+   * Nodes matching a query by the value of a key, e.g. every LineRef by its
+   * `@ref`
+   */
+  export interface Index {
+    /** Nodes with the key in document order, empty if there are none */
+    get(key: string): Node[];
+
+    /** */
+    first(key: string): Result<Node>;
+
+    /** */
+    has(key: string): boolean;
+
+    /** Sorted keys of the index */
+    keys(): string[];
+
+    /** Number of keys */
+    size(): number;
+  }
+
+  export interface Document extends Node {
+    /**
+     * Index of the nodes matching pattern by the value of key relative to
+     * each node, built on first use and shared by every script running on
+     * the document
+     * @param {string} pattern e.g. ".//LineRef"
+     * @param {string} key e.g. "@ref"
+     */
+    index(pattern: string, key: string): Result<Index>;
+
+    /**
+     * Element of type with the given `@id`
+     * @param {string} type e.g. "ServiceJourney"
+     * @param {string} id
+     */
+    byId(type: string, id: string): Result<Node>;
+  }
+
   export interface Collection {
     /** */
     find(pattern: string): Result<Node[]>;
 
     /** */
     first(pattern: string): Result<Node>;
+
+    /** Index over every document, see Document.index */
+    index(pattern: string, key: string): Result<Index>;
+
+    /** Element of type with the given `@id` in any document */
+    byId(type: string, id: string): Result<Node>;
   }
 
   export enum LogLevel {
@@ -121,12 +181,80 @@ declare module "types" {
      * @param {string} version
      */
     parse(version: string): Result<Node>;
+
+    /**
+     * Checks the identity constraints (key, keyref and unique) of the schema
+     * against the document, or every document in collection scoped scripts
+     * @param {string} version defaults to "netex@1.2"
+     * @param {ConstraintKind[]} kinds kinds to check, all if none
+     */
+    constraints(version?: string, ...kinds: ConstraintKind[]): Result<ConstraintViolation[]>;
+  }
+
+  export type ConstraintKind = "key" | "keyref" | "unique";
+
+  export interface Constraint {
+    kind: ConstraintKind;
+    name: string;
+    /** Name of the element declaring the constraint */
+    scope: string;
+    /** Name of the key referred to by a keyref */
+    refer: string;
+    selector: string;
+    fields: string[];
+  }
+
+  export interface ConstraintViolation {
+    kind: "duplicate" | "missingField" | "missingKey";
+    constraint: Constraint;
+    node: Node;
+    /** Field values joined by ";" */
+    key: string;
+    /** Present fields, e.g. `@ref="SE:005:Line:1", @version="1"` */
+    descriptor: string;
   }
 
+  /**
+   * Scripts are run once for every document ("document", the default) or once
+   * for every validation against the collection of all documents
+   * ("collection"), declared with `const scope = "collection";`
+   */
+  export type Scope = "document" | "collection";
+
+  /**
+   * Example of a finding reported by a script, declared with
+   * `const examples = [{ code: "NETEX-REF-001", message: "..." }];` next to
+   * the other metadata of the script (`description`, `version`, `author`,
+   * `tags`, `netexVersions` and `documentation`)
+   */
+  export interface ScriptExample {
+    code: string;
+    message: string;
+    description?: string;
+  }
+
+  /**
+   * Option accepted in the config of a script, declared with
+   * `const configSchema = { distance: { type: "number", default: 500 } };`.
+   * Profiles setting unknown options or invalid values are rejected.
+   */
+  export interface ConfigOption {
+    type: "number" | "integer" | "string" | "boolean" | "array";
+    default?: any;
+    enum?: any[];
+    min?: number;
+    max?: number;
+    description?: string;
+  }
+
+  export type ConfigSchema = { [option: string]: ConfigOption };
+
   export interface Context {
+    /** Config of the script in the profile, with the defaults of its schema */
     config: M;
     params: M;
-    document: Node;
+    /** Not available in collection scoped scripts */
+    document: Document;
     collection: Collection;
     log: Logger;
     node: Node;
@@ -135,10 +263,88 @@ declare module "types" {
   }
 }
 
+declare module "geo" {
+  import { Node, Result } from "types";
+
+  /** WGS84 coordinate in degrees */
+  export type Point = { lat: number, lon: number };
+
+  export interface BBox {
+    minLat: number;
+    minLon: number;
+    maxLat: number;
+    maxLon: number;
+
+    contains(p: Point): boolean;
+  }
+
+  /** Great-circle distance between two points in meters */
+  export function distance(a: Point, b: Point): number;
+
+  /** Initial bearing from a to b in degrees (0-360) */
+  export function bearing(a: Point, b: Point): number;
+
+  export function bbox(points: Point[]): BBox;
+
+  /** Whether p is inside polygon */
+  export function contains(polygon: Point[], p: Point): boolean;
+
+  /**
+   * Converts x (easting) and y (northing) to WGS84, srs is e.g. "EPSG:3006"
+   * (supported are WGS84, ETRS89, SWEREF99 TM and ETRS89/WGS84 UTM zones)
+   */
+  export function transform(x: number, y: number, srs: string): Result<Point>;
+
+  /**
+   * WGS84 coordinate of a Location element, from Longitude/Latitude or
+   * gml:pos (in srs unless the element declares its own srsName)
+   */
+  export function location(node: Node, srs: string): Result<Point>;
+
+  export function isWGS84(srs: string): boolean;
+
+  export function isSupported(srs: string): boolean;
+}
+
 declare module "time" {
   import { M, Result } from "types";
 
   export function validLocation(name: string): Result<boolean>;
+
+  /**
+   * Seconds since midnight of an xsd:time (e.g. "13:45:00") as written, a
+   * time zone is not applied
+   */
+  export function parseTime(v: string): Result<number>;
+
+  /** Seconds of an xsd:duration (e.g. "PT1H30M"), years and months are rejected */
+  export function parseDuration(v: string): Result<number>;
+
+  /** Unix time of an xsd:dateTime, zone is used if v has no time zone */
+  export function parseDateTime(v: string, zone: string): Result<number>;
+
+  /** A DayOffset, a missing (null) offset is 0 */
+  export function parseDayOffset(v: string | number | null): Result<number>;
+
+  /**
+   * Seconds from midnight of the operating day of a time and its day offset,
+   * ignoring daylight saving time changes. A time with a time zone of its own
+   * is converted to zone (e.g. TimeZone of FrameDefaults) if given.
+   */
+  export function passingTime(v: string, dayOffset: string | number | null, zone?: string): Result<number>;
+
+  /**
+   * Unix time of a time and day offset on the operating day date (xsd:date)
+   * in zone (e.g. "Europe/Stockholm") unless v has a time zone of its own,
+   * respecting daylight saving time
+   */
+  export function absolute(date: string, v: string, dayOffset: string | number | null, zone: string): Result<number>;
+
+  /** Offset from UTC in seconds of zone at the Unix time */
+  export function offset(zone: string, unix: number): Result<number>;
+
+  /** Formats seconds from midnight as "HH:MM:SS", followed by the day offset ("+1") */
+  export function formatTime(secs: number): string;
 }
 
 declare module "xpath" {
@@ -162,15 +368,45 @@ declare module "errors" {
   export const TYPE_NOT_FOUND: Error;
   export const TYPE_QUALITY: Error;
 
+  export const SEVERITY_ERROR: Severity;
+  export const SEVERITY_WARNING: Severity;
+  export const SEVERITY_INFO: Severity;
+
+  /** Only findings with severity "error" makes a document invalid */
+  export type Severity = "error" | "warning" | "info";
+
   export type ScriptError = {
     type: Error;
+    severity: Severity;
     message: string;
     extra: M;
   }
 
-  export function create(type: Error, message: string | Error, extra?: M): ScriptError;
-  export function ConsistencyError(message: string | Error, extra?: M): ScriptError;
-  export function GeneralError(message: string | Error, extra?: M): ScriptError;
-  export function NotFoundError(message: string | Error, extra?: M): ScriptError;
-  export function QualityError(message: string | Error, extra?: M): ScriptError;
+  /**
+   * Location and details of an error. If `node` is set, the fields `line`,
+   * `column`, `path`, `id` and `version` not set explicitly are read from it.
+   * `code` is a stable identifier of the kind of finding (e.g.
+   * "NETEX-REF-001") and `params` its structured details (e.g. type, id,
+   * expected and actual).
+   */
+  export type Extra = {
+    code?: string;
+    params?: M;
+    node?: import("types").Node;
+    line?: number;
+    column?: number;
+    path?: string;
+    id?: string;
+    version?: string;
+    severity?: Severity;
+    document?: string;
+    [key: string]: any;
+  }
+
+  /** The severity of the error is read from `extra.severity` (defaults to "error") */
+  export function create(type: Error, message: string | Error, extra?: Extra): ScriptError;
+  export function ConsistencyError(message: string | Error, extra?: Extra): ScriptError;
+  export function GeneralError(message: string | Error, extra?: Extra): ScriptError;
+  export function NotFoundError(message: string | Error, extra?: Extra): ScriptError;
+  export function QualityError(message: string | Error, extra?: Extra): ScriptError;
 }
\ No newline at end of file
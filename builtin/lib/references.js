@@ -0,0 +1,61 @@
+/**
+ * Helpers shared by the rules making sure elements are referenced from other
+ * elements of the same document
+ */
+const errors = require("errors");
+const types = require("types");
+
+/**
+ * @typedef {{
+ *   type: string,
+ *   path: string,
+ *   ref: string,
+ *   missingIdMessage?: string,
+ * }} ReferencedOptions
+ */
+
+/**
+ * Make sure every element at path is referenced by an element of type ref
+ * (e.g. "LineRef") in the document, elements without an @id are reported as
+ * well
+ * @param {types.Context} ctx
+ * @param {ReferencedOptions} opts
+ * @return {errors.ScriptError[]}
+ */
+function everyIsReferenced(ctx, opts) {
+  const refs = ctx.document.index(`.//${opts.ref}`, "@ref").get();
+  const missingIdMessage = opts.missingIdMessage || `${opts.type} is missing attribute @id`;
+
+  return ctx.node.find(opts.path)
+    .map(v => v.reduce((res, node) => {
+      const id = node.attr("id").get();
+
+      if (!id) {
+        res.push(errors.ConsistencyError(
+          missingIdMessage,
+          { node, code: "NETEX-ID-001", params: { type: opts.type, expected: "@id" } },
+        ));
+        return res;
+      }
+
+      if (!refs.has(id)) {
+        res.push(errors.ConsistencyError(
+          `Missing reference for ${opts.type}(@id=${id})`,
+          { node, code: "NETEX-REF-001", params: { type: opts.type, id, expected: opts.ref } },
+        ));
+      }
+
+      return res;
+    }, /** @type {errors.ScriptError[]} */ ([])))
+    .getOrElse(err => {
+      if (err == errors.NODE_NOT_FOUND) {
+        return [];
+      } else if (err) {
+        return [errors.GeneralError(err)];
+      }
+    });
+}
+
+module.exports = {
+  everyIsReferenced,
+};
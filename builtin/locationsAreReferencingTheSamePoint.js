@@ -4,7 +4,30 @@
  * @author Concrete IT
  */
 const name = "locationsAreReferencingTheSamePoint";
+const description = "Locations are referencing the same point";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["geo", "stops"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure the <Location /> of the <StopPlace /> and <ScheduledStopPoint /> of " +
+  "every <PassengerStopAssignment /> are pointing to the same coordinates, i.e. " +
+  "are no further apart than the configured distance.";
+const examples = [
+  { code: "NETEX-GEO-001", message: "ScheduledStopPoint and StopPlace is too far apart (PassengerStopAssignment @id=SE:005:PassengerStopAssignment:1)" },
+  { code: "NETEX-REF-002", message: "Missing ScheduledStopPoint (PassengerStopAssignment @id=SE:005:PassengerStopAssignment:1)" },
+  { code: "NETEX-GEO-004", message: "Invalid location for StopPlace(@id=SE:005:StopPlace:1): invalid position '59.33'" },
+];
+const configSchema = {
+  distance: {
+    type: "number",
+    default: 100,
+    min: 0,
+    description: "Max distance in meters between the locations of a StopPlace and a ScheduledStopPoint",
+  },
+};
 const errors = require("errors");
+const geo = require("geo");
 const types = require("types");
 const xpath = require("xpath");
 const passengerStopAssignmentsPath = xpath.join(
@@ -17,18 +40,19 @@ const stopPlacesPath = xpath.join(
   xpath.path.FRAMES,
   "SiteFrame",
   "stopPlaces",
+  "StopPlace",
 );
 const scheduledStopPointsPath = xpath.join(
   xpath.path.FRAMES,
   "ServiceFrame",
   "scheduledStopPoints",
+  "ScheduledStopPoint",
 );
 const scheduledStopPointRefPath = xpath.join("ScheduledStopPointRef");
 const stopPlaceRefPath = xpath.join("StopPlaceRef");
-const spLongitudePath = xpath.join("Centroid", "Location", "Longitude");
-const spLatitudePath = xpath.join("Centroid", "Location", "Latitude");
-const sspLongitudePath = xpath.join("Location", "Longitude");
-const sspLatitudePath = xpath.join("Location", "Latitude");
+const spLocationPath = xpath.join("Centroid", "Location");
+const sspLocationPath = xpath.join("Location");
+const defaultLocationSystemPath = xpath.join(xpath.path.FRAME_DEFAULTS, "DefaultLocationSystem");
 
 /**
  * Make sure every Location in StopPlace and ScheduledStopPoint for the same
@@ -46,39 +70,51 @@ function main(ctx) {
 
 function worker(ctx) {
   const { node } = ctx;
-  const config = { distance: 100, ...ctx.config };
+  const { config } = ctx;
   const id = node.attr("id").get();
   const scheduledStopPoint = node.first(scheduledStopPointRefPath)
     .map(n => n.attr("ref").get())
-    .map(n => xpath.join(scheduledStopPointsPath, `ScheduledStopPoint[@id='${n}']`))
-    .map(p => ctx.document.first(p).get())
+    .map(n => ctx.document.index(scheduledStopPointsPath, "@id").get().first(n).get())
     .get();
 
   if (!scheduledStopPoint) {
     return [errors.ConsistencyError(
       `Missing ScheduledStopPoint (PassengerStopAssignment @id=${id})`,
-      { line: node.line() },
+      { node, code: "NETEX-REF-002", params: { type: "PassengerStopAssignment", id, expected: "ScheduledStopPoint" } },
     )];
   }
 
   const stopPlace = node.first(stopPlaceRefPath)
     .map(n => n.attr("ref").get())
-    .map(n => xpath.join(stopPlacesPath, `StopPlace[@id='${n}']`))
-    .map(p => ctx.document.first(p).get())
+    .map(n => ctx.document.index(stopPlacesPath, "@id").get().first(n).get())
     .get();
 
   if (!stopPlace) {
     return [errors.ConsistencyError(
       `Missing StopPoint (PassengerStopAssignment @id=${id})`,
-      { line: node.line() },
+      { node, code: "NETEX-REF-002", params: { type: "PassengerStopAssignment", id, expected: "StopPlace" } },
     )];
   }
 
-  const distance = getDistance(stopPlace, scheduledStopPoint); // argument order is important!
+  const srs = ctx.document.textAt(defaultLocationSystemPath).getOrElse(() => "EPSG:4326");
+  const spLocation = locationOf(stopPlace, spLocationPath, srs);
+  const sspLocation = locationOf(scheduledStopPoint, sspLocationPath, srs);
+  const res = [];
+  if (spLocation.error) {
+    res.push(invalidLocation(stopPlace, "StopPlace", spLocation.error));
+  }
+  if (sspLocation.error) {
+    res.push(invalidLocation(scheduledStopPoint, "ScheduledStopPoint", sspLocation.error));
+  }
+  if (res.length > 0) {
+    return res;
+  }
+
+  const distance = Math.round(geo.distance(spLocation.point, sspLocation.point));
   if (distance > config.distance) {
     return [errors.ConsistencyError(
       `ScheduledStopPoint and StopPlace is too far apart (PassengerStopAssignment @id=${id})`,
-      { line: node.line() },
+      { node, code: "NETEX-GEO-001", params: { type: "PassengerStopAssignment", id, expected: config.distance, actual: distance } },
     )];
   }
 
@@ -86,48 +122,35 @@ function worker(ctx) {
 }
 
 /**
- * Calculate the distance between two nodes
- * @param {types.Node} n1
- * @param {types.Node} n2
- * @returns {number}
+ * Resolve the Location at path of a StopPlace or ScheduledStopPoint, a
+ * location which is missing or can't be resolved (e.g. an invalid gml:pos or
+ * an unsupported srsName) has an error.
+ * @param {types.Node} node
+ * @param {string} path
+ * @param {string} srs
+ * @returns {{ point?: geo.Point, error?: string }}
  */
-function getDistance(n1, n2) {
-  const lonx = parseFloat(n1.textAt(spLongitudePath).get());
-  const latx = parseFloat(n1.textAt(spLatitudePath).get());
-  const lony = parseFloat(n2.textAt(sspLongitudePath).get());
-  const laty = parseFloat(n2.textAt(sspLatitudePath).get());
-  const d = getDistanceFromLatLonInKm(lonx, latx, lony, laty);
-
-  return Math.round(d * 1000);
-}
-
-/**
- * Calculate the distance between two points
- * https://stackoverflow.com/questions/27928/calculate-distance-between-two-latitude-longitude-points-haversine-formula
- * @param {number} lat1
- * @param {number} lon1
- * @param {number} lat2
- * @param {number} lon2
- * @returns {number}
- */
-function getDistanceFromLatLonInKm(lat1, lon1, lat2, lon2) {
-  const R = 6371; // radius of the earth in km
-  const dLat = deg2rad(lat2 - lat1); // deg2rad below
-  const dLon = deg2rad(lon2 - lon1);
-  const a =
-    Math.sin(dLat / 2) * Math.sin(dLat / 2) +
-    Math.cos(deg2rad(lat1)) * Math.cos(deg2rad(lat2)) *
-    Math.sin(dLon / 2) * Math.sin(dLon / 2);
-  const c = 2 * Math.atan2(Math.sqrt(a), Math.sqrt(1 - a));
-  const d = R * c; // distance in km
+function locationOf(node, path, srs) {
+  const location = node.first(path).get();
+  if (!location) {
+    return { error: `missing ${path}` };
+  }
 
-  return d;
+  return geo.location(location, srs)
+    .map(point => ({ point }))
+    .getOrElse(err => ({ error: `${err}` }));
 }
 
 /**
- * @param {number} deg
- * @returns {number}
+ * @param {types.Node} node
+ * @param {string} type
+ * @param {string} error
+ * @returns {errors.ScriptError}
  */
-function deg2rad(deg) {
-  return deg * (Math.PI / 180);
+function invalidLocation(node, type, error) {
+  const id = node.attr("id").get();
+  return errors.ConsistencyError(
+    `Invalid location for ${type}(@id=${id}): ${error}`,
+    { node, code: "NETEX-GEO-004", params: { type, id, actual: error } },
+  );
 }
@@ -4,68 +4,30 @@
  * @author Concrete IT
  */
 const name = "netexUniqueConstraints";
+const description = "Validate NeTEx element uniqueness";
+const version = "1.0.0";
+const author = "Concrete IT";
+const tags = ["constraints"];
+const netexVersions = ["netex@1.2"];
+const documentation =
+  "Validate the unique constraints of the NeTEx 1.2 schema, e.g. that no two " +
+  "elements of the same type share the same @id and @version.";
+const examples = [
+  { code: "NETEX-UNIQUE-001", message: "Duplicate reference violates unique constraint \"PointOnRoute_UniqueBy_Id_Version_Order\" (key: SE:005:PointOnRoute:55700000061756910;any;1)" },
+];
 const errors = require("errors");
 const types = require("types");
 
 /**
- * Make sure every Line is referenced from another element
+ * Make sure the unique constraints of the schema hold
  * @param {types.Context} ctx
  * @return {errors.ScriptError[]?}
  */
 function main(ctx) {
-  const xsd = ctx.xsd.parse("netex@1.2").get();
-
-  mapConstraints(xsd, ".//xsd:unique")
-    .forEach(v => ctx.worker.queue("uniqueConstraints", ctx.document, v));
-
-  return ctx.worker.run().get();
-}
-
-/**
- * @param {types.Node} node
- * @param {string} selector
- */
-function mapConstraints(node, selector) {
-  return node.find(selector)
+  return ctx.xsd.constraints("netex@1.2", "unique")
     .get()
-    .map(n => {
-      return {
-        name: n.attr("name").get(),
-        selector: n.first(".//xsd:selector").get().attr("xpath").get().replace(/netex:/g, ""),
-        fields: n.find(".//xsd:field").get().map(n => n.attr("xpath").get()),
-      };
-    });
-}
-
-/**
- * @param {types.Context} ctx
- * @return {errors.ScriptError[]?}
- */
-function uniqueConstraints(ctx) {
-  const res = [];
-
-  ctx.node.find(ctx.params.selector)
-    .getOrElse(() => [])
-    .reduce((/** @type {Record<string, boolean>} */ o, /** @type {types.Node} */ n) => {
-      const k = attrHash(n, ctx.params.fields);
-      if (o[k]) {
-        res.push(errors.ConsistencyError(
-          `Duplicate reference violates unique constraint "${ctx.params.name}" (key: ${k})`,
-          { line: n.line() },
-        ));
-      }
-      o[k] = true
-      return o
-    }, {});
-
-  return res
-}
-
-/**
- * @param {types.Node} node
- * @param {string[]} fields
- * @return {string}
- */
-function attrHash(node, fields) {
-  return fields.map((/** @type {string} */ v) => node.textAt(v).get()).join(";");
+    .map(v => errors.ConsistencyError(
+      `Duplicate reference violates unique constraint "${v.constraint.name}" (key: ${v.key})`,
+      { node: v.node, code: "NETEX-UNIQUE-001", params: { constraint: v.constraint.name, key: v.key } },
+    ));
 }
@@ -4,17 +4,32 @@
  * @author Concrete IT
  */
 const name = "passingTimesIsNotDecreasing";
+const description = "Passing times don't have decreasing times";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["timetable"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Make sure the passing times of every <ServiceJourney /> don't decrease from " +
+  "one stop to the next, taking day offsets into account, and that day offsets " +
+  "don't decrease either.";
+const examples = [
+  { code: "NETEX-TIME-002", message: "Expected passing time to not decrease in ServiceJourney(@id=SE:005:ServiceJourney:55700000063232643), TimetabledPassingTime(@id=SE:005:TimetabledPassingTime:55700000063232628)" },
+  { code: "NETEX-TIME-003", message: "DepartureDayOffset must not decrease in sequence in ServiceJourney(@id=SE:005:ServiceJourney:55700000063232643), TimetabledPassingTime(@id=SE:005:TimetabledPassingTime:55700000063232628)" },
+];
 const errors = require("errors");
+const time = require("time");
 const types = require("types");
 const xpath = require("xpath");
 const serviceJourneyPath = xpath.join(xpath.path.FRAMES, "TimetableFrame", "vehicleJourneys", "ServiceJourney");
 const timetablePath = xpath.join("passingTimes", "TimetabledPassingTime");
-const stopPointPath = xpath.join(xpath.path.FRAMES, "ServiceFrame", "journeyPatterns", "*[contains(name(), 'JourneyPattern')]", "pointsInSequence");
+const stopPointPath = xpath.join(xpath.path.FRAMES, "ServiceFrame", "journeyPatterns", "*", "pointsInSequence", "StopPointInJourneyPattern");
 const stopPointRefPath = xpath.join("StopPointInJourneyPatternRef/@ref");
 const arrivalTimePath = xpath.join("ArrivalTime");
 const arrivalOffsetPath = xpath.join("ArrivalDayOffset");
 const departureTimePath = xpath.join("DepartureTime");
 const departureOffsetPath = xpath.join("DepartureDayOffset");
+const timeZonePath = xpath.join(xpath.path.FRAME_DEFAULTS, "DefaultLocale", "TimeZone");
 
 /**
  * Makes sure passing times don't have decreasing times and day offsets
@@ -22,64 +37,121 @@ const departureOffsetPath = xpath.join("DepartureDayOffset");
  * @return {errors.ScriptError[]?}
  */
 function main(ctx) {
+  const zone = frameTimeZone(ctx);
   ctx.node.find(serviceJourneyPath)
     .getOrElse(() => [])
-    .forEach((n) => ctx.worker.queue("worker", n));
+    .forEach((n) => ctx.worker.queue("worker", n, { zone }));
 
   return ctx.worker.run().get();
 }
 
+/**
+ * The time zone of the document, as given by TimeZone of FrameDefaults, an
+ * empty zone if missing or invalid (times are taken as written)
+ * @param {types.Context} ctx
+ * @return {string}
+ */
+function frameTimeZone(ctx) {
+  const zone = ctx.document.textAt(timeZonePath).get();
+  if (zone === null || !time.validLocation(zone).getOrElse(() => false)) {
+    return "";
+  }
+
+  return zone;
+}
+
+/**
+ * Compares the passing times of a ServiceJourney as seconds from midnight of
+ * the operating day in the time zone of the document, a missing day offset is
+ * the same as an offset of 0. A decreasing day offset is reported on its own,
+ * not as a decreasing passing time as well.
+ * @param {types.Context} ctx
+ * @return {errors.ScriptError[]?}
+ */
 function worker(ctx) {
   const res = [];
+  const zone = ctx.params.zone;
   const passingTimes = ctx.node.find(timetablePath).get();
   const id = ctx.node.attr("id").get();
-  let prevArrivalDayOffset;
-  let prevDepartureTime;
-  let prevDepartureDayOffset;
+  const stopPoints = ctx.document.index(stopPointPath, "@id").get();
+  let prevTime = null;
+  let prevArrivalDayOffset = 0;
+  let prevDepartureDayOffset = 0;
 
   passingTimes.forEach((node, i) => {
     const tid = node.attr("id").get();
     const stopPointID = node.textAt(stopPointRefPath).get();
-    const arrivalTime = node.textAt(arrivalTimePath).get();
-    const arrivalDayOffset = node.textAt(arrivalOffsetPath).get();
-    const departureTime = node.textAt(departureTimePath).get();
-    const departureDayOffset = node.textAt(departureOffsetPath).get();
+    const arrivalDayOffset = dayOffset(node, arrivalTimePath, arrivalOffsetPath);
+    const departureDayOffset = dayOffset(node, departureTimePath, departureOffsetPath);
+    const arrival = passingTime(node, arrivalTimePath, arrivalOffsetPath, zone);
+    const departure = passingTime(node, departureTimePath, departureOffsetPath, zone);
+    const current = arrival ?? departure;
+    let offsetDecreased = false;
 
-    if (i !== 0) {
-      if (prevDepartureTime > arrivalTime && arrivalDayOffset === prevArrivalDayOffset) {
-        res.push(errors.ConsistencyError(
-          `Expected passing time to not decrease in ServiceJourney(@id=${id}), TimetabledPassingTime(@id=${tid})`,
-          { line: node.line() },
-        ));
-      }
-    }
-    if (arrivalDayOffset && prevArrivalDayOffset && arrivalDayOffset < prevArrivalDayOffset) {
+    if (arrivalDayOffset !== null && arrivalDayOffset < prevArrivalDayOffset) {
+      offsetDecreased = true;
       res.push(errors.ConsistencyError(
         `ArrivalDayOffset must not decrease in sequence in ServiceJourney(@id=${id}), TimetabledPassingTime(@id=${tid})`,
-        { line: node.line() },
+        { node, code: "NETEX-TIME-003", params: { type: "TimetabledPassingTime", id: tid, serviceJourney: id, expected: prevArrivalDayOffset, actual: arrivalDayOffset } },
       ));
     }
-    if (departureDayOffset && prevDepartureDayOffset && departureDayOffset < prevDepartureDayOffset) {
+    if (departureDayOffset !== null && departureDayOffset < prevDepartureDayOffset) {
+      offsetDecreased = true;
       res.push(errors.ConsistencyError(
         `DepartureDayOffset must not decrease in sequence in ServiceJourney(@id=${id}), TimetabledPassingTime(@id=${tid})`,
-        { line: node.line() },
+        { node, code: "NETEX-TIME-003", params: { type: "TimetabledPassingTime", id: tid, serviceJourney: id, expected: prevDepartureDayOffset, actual: departureDayOffset } },
+      ));
+    }
+    if (!offsetDecreased && i !== 0 && prevTime !== null && current !== null && current < prevTime) {
+      res.push(errors.ConsistencyError(
+        `Expected passing time to not decrease in ServiceJourney(@id=${id}), TimetabledPassingTime(@id=${tid})`,
+        { node, code: "NETEX-TIME-002", params: { type: "TimetabledPassingTime", id: tid, serviceJourney: id, expected: time.formatTime(prevTime), actual: time.formatTime(current) } },
       ));
     }
 
-    prevArrivalDayOffset = arrivalDayOffset;
-    prevDepartureTime = departureTime;
-    prevDepartureDayOffset = departureDayOffset;
+    prevTime = departure ?? arrival ?? prevTime;
+    prevArrivalDayOffset = arrivalDayOffset ?? prevArrivalDayOffset;
+    prevDepartureDayOffset = departureDayOffset ?? prevDepartureDayOffset;
 
-    if (!ctx.document.find(xpath.join(
-      stopPointPath,
-      `StopPointInJourneyPattern[@id = '${stopPointID}']`,
-    ))) {
+    if (!stopPoints.has(stopPointID)) {
       res.push(errors.ConsistencyError(
-        `Expected StopPointInJourneyPattern(@id=${id}`,
-        { line: node.line() },
+        `Expected StopPointInJourneyPattern(@id=${stopPointID})`,
+        { node, code: "NETEX-REF-003", params: { type: "TimetabledPassingTime", id: tid, expected: stopPointID } },
       ));
     }
   });
 
   return res;
 }
+
+/**
+ * @param {types.Node} node
+ * @param {string} timePath
+ * @param {string} offsetPath
+ * @return {number?} the day offset of the time, 0 if the offset is missing and
+ * null if the time is missing or the offset is invalid
+ */
+function dayOffset(node, timePath, offsetPath) {
+  if (node.textAt(timePath).get() === null) {
+    return null;
+  }
+
+  return time.parseDayOffset(node.textAt(offsetPath).get()).getOrElse(() => null);
+}
+
+/**
+ * @param {types.Node} node
+ * @param {string} timePath
+ * @param {string} offsetPath
+ * @param {string} zone
+ * @return {number?} seconds from midnight of the operating day, null if the
+ * time is missing or invalid
+ */
+function passingTime(node, timePath, offsetPath, zone) {
+  const v = node.textAt(timePath).get();
+  if (v === null) {
+    return null;
+  }
+
+  return time.passingTime(v, node.textAt(offsetPath).get(), zone).getOrElse(() => null);
+}
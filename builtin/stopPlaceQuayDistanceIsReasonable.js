@@ -4,21 +4,35 @@
  * @author Concrete IT
  */
 const name = "stopPlaceQuayDistanceIsReasonable";
+const description = "Stop place quay distance is reasonable";
+const version = "0.0.1";
+const author = "Concrete IT";
+const tags = ["geo", "stops"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Check the distance between the centroid of every <StopPlace /> and its " +
+  "<Quay />s, which must not be greater than the configured distance.";
+const examples = [
+  { code: "NETEX-GEO-002", message: "Distance between StopPlace and Quay greater than 500m (stopPlace @id=SE:005:StopPlace:1, Quay @id=SE:005:Quay:1, distance=812m)" },
+  { code: "NETEX-FRAME-003", message: "Element <FrameDefaults /> is missing child <DefaultLocationSystem />" },
+  { code: "NETEX-GEO-004", message: "Invalid location for Quay(@id=SE:005:Quay:1): invalid position '59.33'" },
+];
+const configSchema = {
+  distance: {
+    type: "number",
+    default: 500,
+    min: 0,
+    description: "Max distance in meters between a StopPlace and its Quays",
+  },
+};
 const errors = require("errors");
+const geo = require("geo");
 const types = require("types");
 const xpath = require("xpath");
 const defaultLocationSystemPath = xpath.join(".", "DefaultLocationSystem");
-const framesPath = xpath.join(
-  ".",
-  "PublicationDelivery",
-  "dataObjects",
-  "CompositeFrame",
-  "frames",
-);
-const stopPlacesPath = xpath.join(framesPath, "SiteFrame", "stopPlaces", "StopPlace");
+const stopPlacesPath = xpath.join(xpath.path.FRAMES, "SiteFrame", "stopPlaces", "StopPlace");
 const quayPath = xpath.join("quays", "Quay");
-const longitudePath = xpath.join("Centroid", "Location", "Longitude");
-const latitudePath = xpath.join("Centroid", "Location", "Latitude");
+const locationPath = xpath.join("Centroid", "Location");
 
 /**
  * Check the distance between a StopPlace and its Quays
@@ -26,12 +40,15 @@ const latitudePath = xpath.join("Centroid", "Location", "Latitude");
  * @return {errors.ScriptError[]?}
  */
 function main(ctx) {
-  const config = { distance: 500, ...ctx.config };
+  const { config } = ctx;
   const res = [];
-  const frameDefaults = ctx.document.first(xpath.path.FRAME_DEFAULTS).get(); // Find the LocationSystem and verify that it is WGS84 / 4326
+  const frameDefaults = ctx.document.first(xpath.path.FRAME_DEFAULTS).get(); // Find the LocationSystem and verify that it is supported
 
   if (!frameDefaults) {
-    return [errors.NotFoundError("Document is missing element <FrameDefaults />")];
+    return [errors.NotFoundError(
+      "Document is missing element <FrameDefaults />",
+      { code: "NETEX-FRAME-001", params: { expected: "FrameDefaults" } },
+    )];
   }
 
   const defaultLocationSystem = frameDefaults.textAt(defaultLocationSystemPath).get();
@@ -40,9 +57,15 @@ function main(ctx) {
   ctx.log.debug(`configured max distance: ${config.distance}`);
 
   if (!defaultLocationSystem) {
-    return [errors.GeneralError("Element <FrameDefaults /> is missing child <DefaultLocationSystem />")];
-  } else if (!(defaultLocationSystem.includes("4326") || defaultLocationSystem.includes("WGS84"))) {
-    return [errors.GeneralError("Document coordinates is not in WGS84/EPSG:4326")];
+    return [errors.GeneralError(
+      "Element <FrameDefaults /> is missing child <DefaultLocationSystem />",
+      { code: "NETEX-FRAME-003", params: { expected: "DefaultLocationSystem" } },
+    )];
+  } else if (!geo.isSupported(defaultLocationSystem)) {
+    return [errors.GeneralError(
+      `Document coordinates are in an unsupported location system (${defaultLocationSystem})`,
+      { code: "NETEX-GEO-003", params: { expected: "EPSG:4326", actual: defaultLocationSystem } },
+    )];
   }
 
   // Find all stopPlaces and check the distance to the quays
@@ -50,22 +73,36 @@ function main(ctx) {
     .getOrElse(() => [])
     .forEach(node => {
       const id = node.attr("id").get();
-      const long = parseFloat(node.textAt(longitudePath).get());
-      const lat = parseFloat(node.textAt(latitudePath).get());
+      const location = locationOf(node, defaultLocationSystem);
+      if (location.error) {
+        res.push(invalidLocation(node, "StopPlace", id, location.error));
+      }
+      if (!location.point) {
+        return;
+      }
 
       node.find(quayPath)
         .getOrElse(() => [])
         .forEach(quay => {
           const idQuay = quay.attr("id").get();
-          const longQuay = parseFloat(quay.textAt(longitudePath).get());
-          const latQuay = parseFloat(quay.textAt(latitudePath).get());
-          const d = getDistanceFromLatLonInKm(lat, long, latQuay, longQuay);
-          const distance = Math.round(d * 1000);
+          const quayLocation = locationOf(quay, defaultLocationSystem);
+          if (quayLocation.error) {
+            res.push(invalidLocation(quay, "Quay", idQuay, quayLocation.error));
+          }
+          if (!quayLocation.point) {
+            return;
+          }
+          const distance = Math.round(geo.distance(location.point, quayLocation.point));
 
           if (distance > config.distance) {
             res.push(errors.QualityError(
-              `Distance between StopPlace and Quay greater than 500m (stopPlace @id=${id}, Quay @id=${idQuay}, distance=${distance}m)`,
-              { line: node.line() },
+              `Distance between StopPlace and Quay greater than ${config.distance}m (stopPlace @id=${id}, Quay @id=${idQuay}, distance=${distance}m)`,
+              {
+                node,
+                severity: errors.SEVERITY_WARNING,
+                code: "NETEX-GEO-002",
+                params: { type: "Quay", id: idQuay, stopPlace: id, expected: config.distance, actual: distance },
+              },
             ))
           }
         });
@@ -75,31 +112,34 @@ function main(ctx) {
 }
 
 /**
- * Calculate the distance between two points
- * https://stackoverflow.com/questions/27928/calculate-distance-between-two-latitude-longitude-points-haversine-formula
- * @param {number} lat1
- * @param {number} lon1
- * @param {number} lat2
- * @param {number} lon2
- * @returns {number}
+ * Resolve the location of a StopPlace or Quay. An element without a location
+ * has neither a point nor an error, a location which can't be resolved (e.g. an
+ * invalid gml:pos or an unsupported srsName) has an error.
+ * @param {types.Node} node
+ * @param {string} srs
+ * @returns {{ point?: geo.Point, error?: string }}
  */
-function getDistanceFromLatLonInKm(lat1, lon1, lat2, lon2) {
-  const R = 6371; // radius of the earth in km
-  const dLat = deg2rad(lat2 - lat1); // deg2rad below
-  const dLon = deg2rad(lon2 - lon1);
-  const a =
-    Math.sin(dLat / 2) * Math.sin(dLat / 2) +
-    Math.cos(deg2rad(lat1)) * Math.cos(deg2rad(lat2)) *
-    Math.sin(dLon / 2) * Math.sin(dLon / 2);
-  const c = 2 * Math.atan2(Math.sqrt(a), Math.sqrt(1 - a));
+function locationOf(node, srs) {
+  const location = node.first(locationPath).get();
+  if (!location) {
+    return {};
+  }
 
-  return R * c; // distance in km
+  return geo.location(location, srs)
+    .map(point => ({ point }))
+    .getOrElse(err => ({ error: `${err}` }));
 }
 
 /**
- * @param {number} deg
- * @returns {number}
+ * @param {types.Node} node
+ * @param {string} type
+ * @param {string} id
+ * @param {string} error
+ * @returns {errors.ScriptError}
  */
-function deg2rad(deg) {
-  return deg * (Math.PI / 180);
+function invalidLocation(node, type, id, error) {
+  return errors.ConsistencyError(
+    `Invalid location for ${type}(@id=${id}): ${error}`,
+    { node, code: "NETEX-GEO-004", params: { type, id, actual: error } },
+  );
 }
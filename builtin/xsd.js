@@ -4,6 +4,29 @@
  * @author Concrete IT
  */
 const name = "xsd";
+const description = "XSD schema validation";
+const version = "1.0.0";
+const author = "Concrete IT";
+const tags = ["schema"];
+const netexVersions = ["netex@1.2", "epip@1.1.2"];
+const documentation =
+  "Validate every document against the XSD schema set in the config (NeTEx 1.2 " +
+  "or EPIP 1.1.2, with or without constraints) or an xsd file. Findings are " +
+  "reported by libxml and their code is the libxml error code.";
+const examples = [
+  { code: "XSD-1871", message: "Element '{http://www.netex.org.uk/netex}CodespaceNOTRIGHT': This element is not expected. Expected is one of ( {http://www.netex.org.uk/netex}CodespaceRef, {http://www.netex.org.uk/netex}Codespace )." },
+];
+const configSchema = {
+  schema: {
+    type: "string",
+    default: "netex@1.2",
+    description: "Schema to validate against, either a supported version (\"netex@1.2\", \"netex@1.2-nc\", \"epip@1.1.2\", \"epip@1.1.2-nc\") or the path of an xsd file",
+  },
+  entry: {
+    type: "string",
+    description: "Id of the uploaded xsd file to validate against when schema is \"custom\" (server only)",
+  },
+};
 const errors = require("errors");
 const types = require("types");
 
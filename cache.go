@@ -0,0 +1,274 @@
+package greenlight
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/concreteit/greenlight/js"
+	"github.com/concreteit/greenlight/xml"
+)
+
+// cacheVersion is part of every cache key, bump it whenever the format of
+// cached results changes
+const cacheVersion = "3"
+
+// schemaLocationRe matches the location of schemas included or imported by a
+// schema
+var schemaLocationRe = regexp.MustCompile(`schemaLocation\s*=\s*["']([^"']+)["']`)
+
+// Cache is an on-disk cache of rule validation results, keyed on the content
+// of the document, the checksum of the script, the script config and the
+// content of the schema in use. Only results of document scripts that ran to
+// completion are cached. If a script read the collection while running, its
+// result is only reused as long as every document of the collection is
+// unchanged.
+type Cache struct {
+	dir string
+}
+
+type cacheEntry struct {
+	// digest of the collection the result was computed with, only set if the
+	// script read the collection
+	Collection string          `json:"collection,omitempty"`
+	Result     *RuleValidation `json:"result"`
+}
+
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		dir: dir,
+	}, nil
+}
+
+func (c *Cache) Dir() string { return c.dir }
+
+func (c *Cache) get(key string) (*cacheEntry, bool) {
+	buf, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(buf, entry); err != nil || entry.Result == nil {
+		return nil, false
+	}
+
+	return entry, true
+}
+
+func (c *Cache) set(key string, entry *cacheEntry) error {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so that concurrent readers never see a
+	// partially written entry
+	f, err := os.CreateTemp(c.dir, "tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), c.path(key))
+}
+
+// Clear removes every cached entry
+func (c *Cache) Clear() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// cacheKey computes the key of a script run on a document, storing at most
+// maxErrors findings
+func (v *Validation) cacheKey(doc *xml.Document, env ScriptEnv, maxErrors int) (string, error) {
+	docChecksum, err := doc.Checksum()
+	if err != nil {
+		return "", err
+	}
+
+	cfg, err := json.Marshal(env.cfg)
+	if err != nil {
+		return "", err
+	}
+
+	schema := ""
+	if s, ok := env.cfg["schema"].(string); ok {
+		if schema, err = v.digests.schema(js.ResolveXSDPath(s)); err != nil {
+			return "", err
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%s\x00%d", cacheVersion, docChecksum, env.rule.Name(), env.rule.Checksum(), cfg, schema, maxErrors)
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// cachedRuleValidation looks up the result of a script run on a document
+func (v *Validation) cachedRuleValidation(doc *xml.Document, env ScriptEnv) (string, *RuleValidation) {
+	key, err := v.cacheKey(doc, env, v.maxErrors)
+	if err != nil {
+		return "", nil
+	}
+
+	entry, ok := v.cache.get(key)
+	if !ok {
+		return key, nil
+	}
+	if entry.Collection != "" {
+		if digest, err := v.digests.collection(v.documentColl); err != nil || digest != entry.Collection {
+			return key, nil
+		}
+	}
+
+	rv := entry.Result
+	rv.Start = time.Now()
+	rv.Stop = rv.Start
+	rv.Cached = true
+
+	return key, rv
+}
+
+// cacheRuleValidation stores the result of a script run on a document,
+// usedCollection reports whether the script read the collection
+func (v *Validation) cacheRuleValidation(key string, rv *RuleValidation, usedCollection bool) error {
+	entry := &cacheEntry{Result: rv}
+	if usedCollection {
+		digest, err := v.digests.collection(v.documentColl)
+		if err != nil {
+			return err
+		}
+		entry.Collection = digest
+	}
+
+	return v.cache.set(key, entry)
+}
+
+// digestCache holds the digests of the schemas and the collection of a
+// validation, each computed once on first use
+type digestCache struct {
+	mu      sync.Mutex
+	schemas map[string]string
+	coll    string
+}
+
+// schema returns the checksum of the content of the schema at xsdPath and of
+// every local schema it includes or imports
+func (c *digestCache) schema(xsdPath string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if digest, ok := c.schemas[xsdPath]; ok {
+		return digest, nil
+	}
+
+	h := sha256.New()
+	visited := map[string]bool{}
+	var walk func(path string) error
+	walk = func(path string) error {
+		if visited[path] {
+			return nil
+		}
+		visited[path] = true
+
+		buf, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.Base(path), len(buf))
+		h.Write(buf)
+
+		for _, m := range schemaLocationRe.FindAllSubmatch(buf, -1) {
+			loc := string(m[1])
+			if strings.Contains(loc, "://") {
+				continue // remote schemas are not covered
+			}
+			if err := walk(filepath.Join(filepath.Dir(path), loc)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+	if err := walk(filepath.Clean(xsdPath)); err != nil {
+		return "", err
+	}
+	digest := fmt.Sprintf("%x", h.Sum(nil))
+
+	if c.schemas == nil {
+		c.schemas = map[string]string{}
+	}
+	c.schemas[xsdPath] = digest
+
+	return digest, nil
+}
+
+// collection returns the checksum of the names and contents of every document
+// of coll
+func (c *digestCache) collection(coll *xml.Collection) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.coll != "" {
+		return c.coll, nil
+	}
+
+	docs := []string{}
+	for _, node := range coll.Nodes() {
+		doc, ok := node.(*xml.Document)
+		if !ok {
+			continue
+		}
+		checksum, err := doc.Checksum()
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, doc.Name+"\x00"+checksum)
+	}
+	sort.Strings(docs)
+
+	h := sha256.New()
+	for _, doc := range docs {
+		fmt.Fprintf(h, "%s\x00", doc)
+	}
+	c.coll = fmt.Sprintf("%x", h.Sum(nil))
+
+	return c.coll, nil
+}
@@ -0,0 +1,118 @@
+package greenlight
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/concreteit/greenlight/xml"
+)
+
+// countingRule reports a finding for every Line of the collection, or of the
+// document only, counting how many times it ran
+type countingRule struct {
+	useCollection bool
+	runs          int
+}
+
+func (r *countingRule) Name() string        { return "countingRule" }
+func (r *countingRule) Description() string { return "" }
+func (r *countingRule) Scope() string       { return "document" }
+func (r *countingRule) Checksum() string    { return "1" }
+
+func (r *countingRule) Validate(ctx context.Context, cfg map[string]interface{}, doc *xml.Document, coll *xml.Collection) (RuleResult, error) {
+	r.runs++
+
+	res := RuleResult{Errors: []TaskError{}}
+	var node xml.Node = doc
+	if r.useCollection {
+		node = coll.Nodes()[0]
+	}
+	if nodes, ok := node.Find("//Line").Get().([]xml.Node); ok {
+		for range nodes {
+			res.Errors = append(res.Errors, TaskError{Message: "line"})
+		}
+	}
+
+	return res, nil
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func validateCached(t *testing.T, cache *Cache, rule Rule, dir string) *RuleValidation {
+	t.Helper()
+
+	v, err := NewValidation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetCache(cache)
+	v.AddRule(rule, nil)
+	for _, name := range []string{"a.xml", "b.xml"} {
+		if err := v.AddFile(name, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := v.Validate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return res[1].ValidationRules[0]
+}
+
+func TestCacheCollection(t *testing.T) {
+	for _, useCollection := range []bool{false, true} {
+		dir := t.TempDir()
+		cache, err := NewCache(filepath.Join(dir, "cache"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(dir, "a.xml"), "<Lines><Line/></Lines>")
+		writeFile(t, filepath.Join(dir, "b.xml"), "<Lines/>")
+
+		rule := &countingRule{useCollection: useCollection}
+		validateCached(t, cache, rule, dir)
+		if rv := validateCached(t, cache, rule, dir); !rv.Cached {
+			t.Errorf("collection %t: expected the result of an unchanged collection to be cached", useCollection)
+		}
+
+		// b.xml is unchanged, its result only changes if it depends on a.xml
+		writeFile(t, filepath.Join(dir, "a.xml"), "<Lines><Line/><Line/></Lines>")
+		rv := validateCached(t, cache, rule, dir)
+		if rv.Cached == useCollection {
+			t.Errorf("collection %t: got cached %t after another document changed", useCollection, rv.Cached)
+		}
+		if useCollection && rv.ErrorCount != 2 {
+			t.Errorf("collection %t: got %d findings, want 2", useCollection, rv.ErrorCount)
+		}
+	}
+}
+
+func TestSchemaDigest(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.xsd"), `<xsd:schema><xsd:include schemaLocation="types/types.xsd"/></xsd:schema>`)
+	os.Mkdir(filepath.Join(dir, "types"), 0o755)
+	writeFile(t, filepath.Join(dir, "types", "types.xsd"), `<xsd:schema><xsd:include schemaLocation="../main.xsd"/></xsd:schema>`)
+
+	before, err := (&digestCache{}).schema(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "types", "types.xsd"), `<xsd:schema/>`)
+	after, err := (&digestCache{}).schema(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if before == after {
+		t.Error("expected the digest to change with the content of an included schema")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/concreteit/greenlight"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	cacheCmd = &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the validation result cache",
+	}
+	cacheDirCmd = &cobra.Command{
+		Use:   "dir",
+		Short: "Print the location of the validation result cache",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(cacheDir(cmd))
+		},
+	}
+	cacheClearCmd = &cobra.Command{
+		Use:   "clear",
+		Short: "Remove every entry in the validation result cache",
+		Run: func(cmd *cobra.Command, args []string) {
+			cache, err := greenlight.NewCache(cacheDir(cmd))
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := cache.Clear(); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+)
+
+func init() {
+	cacheCmd.PersistentFlags().StringP("cache-dir", "", "", "Set location of the validation result cache (defaults to the user cache dir)")
+
+	cacheCmd.AddCommand(cacheDirCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+// cacheDir resolves the location of the cache, from the command flag, the
+// environment (GREENLIGHT_CACHE_DIR) or the user cache dir
+func cacheDir(cmd *cobra.Command) string {
+	if dir, err := cmd.Flags().GetString("cache-dir"); err == nil && dir != "" {
+		return dir
+	}
+	if dir := viper.GetString("cache.dir"); dir != "" {
+		return dir
+	}
+
+	return defaultCacheDir()
+}
+
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+
+	return filepath.Join(dir, "greenlight")
+}
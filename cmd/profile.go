@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"strings"
 
+	"github.com/concreteit/greenlight"
 	"github.com/concreteit/greenlight/internal"
 )
 
@@ -11,6 +14,7 @@ type Profile struct {
 	Name        string   `json:"name"`
 	Description string   `json:"description,omitempty"`
 	Version     string   `json:"version"`
+	MaxErrors   int      `json:"maxErrors,omitempty"`
 	Scripts     []Script `json:"scripts"`
 }
 
@@ -21,12 +25,46 @@ type Script struct {
 	Config      internal.M `json:"config"`
 }
 
+// Validate checks that every script of the profile exists and that its config
+// matches the config schema of the script
+func (p *Profile) Validate() error {
+	msgs := []string{}
+	for _, script := range p.Scripts {
+		var err error
+		if s, ok := scripts[script.Name]; ok {
+			err = greenlight.ValidateScriptConfig(s, script.Config)
+		} else if r, ok := greenlight.LookupRule(script.Name); ok {
+			err = greenlight.ValidateRuleConfig(r, script.Config)
+		} else {
+			err = fmt.Errorf("unable to find rule with the name '%s'", script.Name)
+		}
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("invalid profile '%s': %s", p.Name, strings.Join(msgs, "\n"))
+	}
+
+	return nil
+}
+
 func OpenProfile(path string) (*Profile, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
 	v := &Profile{}
-	return v, json.NewDecoder(f).Decode(v)
+	if err := json.NewDecoder(f).Decode(v); err != nil {
+		return nil, err
+	}
+
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+
+	return v, nil
 }
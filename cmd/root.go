@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -15,9 +16,15 @@ var (
 	}
 )
 
+// errInvalidDocuments is returned by commands reporting invalid documents
+// through the exit status only
+var errInvalidDocuments = errors.New("one or more documents are invalid")
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		if !errors.Is(err, errInvalidDocuments) {
+			fmt.Println(err)
+		}
 		os.Exit(1)
 	}
 }
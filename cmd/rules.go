@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/concreteit/greenlight/js"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rulesCmd = &cobra.Command{
+		Use:     "rules [name]",
+		Short:   "List the available rules, or describe the rule with the given name",
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: loadScripts,
+		Run:     listRules,
+	}
+)
+
+func init() {
+	rulesCmd.Flags().StringP("output", "o", "pretty", "Set which output format to use (one of \"json\", \"pretty\")")
+	rulesCmd.Flags().StringP("tag", "t", "", "Only list rules with the given tag")
+
+	rootCmd.AddCommand(rulesCmd)
+}
+
+func listRules(cmd *cobra.Command, args []string) {
+	output, _ := cmd.Flags().GetString("output")
+	tag, _ := cmd.Flags().GetString("tag")
+
+	var v interface{}
+	infos := []js.ScriptInfo{}
+	if len(args) == 1 {
+		info, ok := ruleInfo(args[0])
+		if !ok {
+			log.Fatalf("unable to find rule with the name '%s'", args[0])
+		}
+		infos = append(infos, info)
+		v = info
+	} else {
+		for _, info := range ruleInfos() {
+			if tag == "" || contains(info.Tags, tag) {
+				infos = append(infos, info)
+			}
+		}
+		v = infos
+	}
+
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			log.Fatal(err)
+		}
+	case "pretty":
+		if len(args) == 1 {
+			describeRule(infos[0])
+		} else {
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tVERSION\tTAGS\tDESCRIPTION")
+			for _, info := range infos {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, info.Version, strings.Join(info.Tags, ","), info.Description)
+			}
+			w.Flush()
+		}
+	default:
+		log.Fatalf("unsupported output format '%s'", output)
+	}
+}
+
+func describeRule(info js.ScriptInfo) {
+	fmt.Printf("%s", info.Name)
+	if info.Version != "" {
+		fmt.Printf(" (%s)", info.Version)
+	}
+	fmt.Println()
+	if info.Description != "" {
+		fmt.Printf("  %s\n", info.Description)
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if info.Author != "" {
+		fmt.Fprintf(w, "Author:\t%s\n", info.Author)
+	}
+	fmt.Fprintf(w, "Scope:\t%s\n", info.Scope)
+	if len(info.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(info.Tags, ", "))
+	}
+	if len(info.NetexVersions) > 0 {
+		fmt.Fprintf(w, "NeTEx versions:\t%s\n", strings.Join(info.NetexVersions, ", "))
+	}
+	w.Flush()
+
+	if info.Documentation != "" {
+		fmt.Printf("\n%s\n", info.Documentation)
+	}
+
+	if len(info.ConfigSchema) > 0 {
+		fmt.Println("\nConfig:")
+		for _, k := range info.ConfigSchema.Keys() {
+			opt := info.ConfigSchema[k]
+			fmt.Printf("  %s (%s", k, opt.Type)
+			if opt.Default != nil {
+				fmt.Printf(", default %v", opt.Default)
+			}
+			fmt.Println(")")
+			if opt.Description != "" {
+				fmt.Printf("    %s\n", opt.Description)
+			}
+		}
+	}
+
+	if len(info.Examples) > 0 {
+		fmt.Println("\nExamples:")
+		for _, ex := range info.Examples {
+			fmt.Printf("  [%s] %s\n", ex.Code, ex.Message)
+			if ex.Description != "" {
+				fmt.Printf("    %s\n", ex.Description)
+			}
+		}
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
@@ -3,11 +3,29 @@ package main
 import (
 	"os"
 	"path"
+	"sort"
 
+	"github.com/concreteit/greenlight"
 	"github.com/concreteit/greenlight/js"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-func compileBuiltin() (js.ScriptMap, error) {
+// loadScripts compiles the builtin scripts, run before commands using them
+// once flags are parsed as the library path of modules is configurable
+func loadScripts(cmd *cobra.Command, args []string) error {
+	scriptMap, err := compileBuiltin(viper.GetStringSlice("lib.path"))
+	if err != nil {
+		return err
+	}
+	scripts = scriptMap
+
+	return nil
+}
+
+// compileBuiltin compiles the scripts of the builtin dir, modules required by
+// name rather than by relative path are resolved from libPaths
+func compileBuiltin(libPaths []string) (js.ScriptMap, error) {
 	scriptMap := js.ScriptMap{}
 	builtinPath := "builtin"
 	scriptPaths, err := os.ReadDir(builtinPath)
@@ -15,17 +33,18 @@ func compileBuiltin() (js.ScriptMap, error) {
 		return nil, err
 	}
 
+	loader := js.NewModuleLoader(libPaths...)
 	for _, entry := range scriptPaths {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".js" {
+			continue
+		}
+
 		buf, err := os.ReadFile(path.Join(builtinPath, entry.Name()))
 		if err != nil {
 			return nil, err
 		}
 
-		if path.Ext(entry.Name()) != ".js" {
-			continue
-		}
-
-		s, err := js.NewScript(entry.Name(), buf)
+		s, err := js.NewScript(path.Join(builtinPath, entry.Name()), buf, js.WithModuleLoader(loader))
 		if err != nil {
 			return nil, err
 		}
@@ -35,3 +54,74 @@ func compileBuiltin() (js.ScriptMap, error) {
 
 	return scriptMap, nil
 }
+
+// ruleNames returns the sorted names of every builtin script and registered
+// native rule
+func ruleNames() []string {
+	names := greenlight.RuleNames()
+	for name := range scripts {
+		if _, ok := greenlight.LookupRule(name); !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// addRule adds the builtin script or registered native rule with the given
+// name to the validation, builtin scripts take precedence. It reports whether
+// a rule with the name exists.
+func addRule(validation *greenlight.Validation, name string, cfg map[string]interface{}) bool {
+	if s, ok := scripts[name]; ok {
+		validation.AddScript(s, cfg)
+		return true
+	}
+	if r, ok := greenlight.LookupRule(name); ok {
+		validation.AddRule(r, cfg)
+		return true
+	}
+
+	return false
+}
+
+// ruleInfos returns the metadata of every builtin script and registered native
+// rule, sorted by name
+func ruleInfos() []js.ScriptInfo {
+	infos := []js.ScriptInfo{}
+	for _, name := range ruleNames() {
+		if info, ok := ruleInfo(name); ok {
+			infos = append(infos, info)
+		}
+	}
+
+	return infos
+}
+
+// ruleInfo returns the metadata of the builtin script or registered native
+// rule with the given name, builtin scripts take precedence
+func ruleInfo(name string) (js.ScriptInfo, bool) {
+	if s, ok := scripts[name]; ok {
+		return s.Info(), true
+	}
+
+	r, ok := greenlight.LookupRule(name)
+	if !ok {
+		return js.ScriptInfo{}, false
+	}
+
+	info := js.ScriptInfo{
+		Name:          r.Name(),
+		Description:   r.Description(),
+		Tags:          []string{},
+		Scope:         r.Scope(),
+		NetexVersions: []string{},
+		Examples:      []js.ScriptExample{},
+		ConfigSchema:  js.ConfigSchema{},
+	}
+	if cr, ok := r.(greenlight.ConfigurableRule); ok && cr.ConfigSchema() != nil {
+		info.ConfigSchema = cr.ConfigSchema()
+	}
+
+	return info, true
+}
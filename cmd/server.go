@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/caarlos0/env/v6"
+	"github.com/concreteit/greenlight"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/spf13/cobra"
@@ -23,6 +24,14 @@ var (
 		Use:   "server",
 		Short: "Start NeTEx validation server",
 		Run:   startServer,
+		// keys shared with the validate command are bound once the command is
+		// known, as only a single flag can be bound per key
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			viper.BindPFlag("rule.timeout", cmd.Flags().Lookup("rule-timeout"))
+			viper.BindPFlag("workers", cmd.Flags().Lookup("workers"))
+
+			return loadScripts(cmd, args)
+		},
 	}
 	sessions = SessionMap{
 		sessions: map[string]*Session{},
@@ -53,6 +62,8 @@ type WebConfig struct {
 
 func init() {
 	serverCmd.Flags().StringP("port", "p", "8080", "Which port to listen http server on")
+	serverCmd.Flags().DurationP("rule-timeout", "", 0, "Abort a single rule running on a single document after the given duration (can be overridden with \"timeout\" in a profile script config)")
+	serverCmd.Flags().IntP("workers", "w", 0, "Set how many documents, rules and rule workers to run at the same time in total, shared by every session (defaults to the number of CPUs)")
 
 	viper.BindPFlag("port", serverCmd.Flags().Lookup("port"))
 
@@ -68,6 +79,7 @@ func startServer(cmd *cobra.Command, args []string) {
 	if port == "" {
 		port = "8080"
 	}
+	sessions.scheduler = greenlight.NewScheduler(viper.GetInt("workers"))
 
 	fs := http.FileServer(StaticDir{http.Dir("app/out")})
 	e := echo.New()
@@ -91,6 +103,10 @@ func startServer(cmd *cobra.Command, args []string) {
 		return c.JSON(http.StatusOK, webConfig)
 	})
 
+	e.GET("/api/scripts", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, ruleInfos())
+	})
+
 	e.POST("/api/sessions", func(c echo.Context) error {
 		s, err := sessions.New()
 		if err != nil {
@@ -130,6 +146,9 @@ func startServer(cmd *cobra.Command, args []string) {
 		if err := c.Bind(profile); err != nil {
 			return err
 		}
+		if err := profile.Validate(); err != nil {
+			return c.String(http.StatusBadRequest, err.Error())
+		}
 
 		session := sessions.Get(c.Param("sid"))
 		if session == nil {
@@ -243,6 +262,7 @@ func startServer(cmd *cobra.Command, args []string) {
 		session.Stopped = time.Now()
 		session.Status = "complete"
 		session.Results = res
+		session.Summary = greenlight.NewSummary(res)
 
 		return c.JSON(http.StatusOK, session)
 	})
@@ -312,12 +332,20 @@ func startServer(cmd *cobra.Command, args []string) {
 		format := c.QueryParam("f")
 		switch format {
 		case "json":
-			return c.JSON(http.StatusOK, session.Results)
+			// summary=false returns the bare array of results, the way the
+			// report was shaped before the summary was added
+			if c.QueryParam("summary") == "false" {
+				return c.JSON(http.StatusOK, session.Results)
+			}
+			return c.JSON(http.StatusOK, ValidationResults{
+				Summary:          session.Summary,
+				ValidationResult: session.Results,
+			})
 		case "csv":
 			var buf bytes.Buffer
 			bw := bufio.NewWriter(&buf)
 			w := csv.NewWriter(bw)
-			w.Write([]string{"file_name", "validation_name", "valid", "error_line_no", "error_message"})
+			w.Write([]string{"file_name", "validation_name", "start", "stop", "valid", "error_line_no", "error_message", "error_severity", "error_column_no", "error_path", "error_id", "error_context", "error_code"})
 
 			for _, res := range session.Results {
 				records := res.CsvRecords(false)
@@ -329,6 +357,11 @@ func startServer(cmd *cobra.Command, args []string) {
 				}
 			}
 
+			// the summary follows the results as a separate table
+			if err := w.WriteAll(append([][]string{{}}, session.Summary.CsvRecords(true)...)); err != nil {
+				return err
+			}
+
 			w.Flush()
 
 			if err := w.Error(); err != nil {
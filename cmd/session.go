@@ -10,6 +10,7 @@ import (
 	"github.com/concreteit/greenlight"
 	petname "github.com/dustinkirkland/golang-petname"
 	gonanoid "github.com/matoous/go-nanoid"
+	"github.com/spf13/viper"
 )
 
 func init() {
@@ -19,6 +20,9 @@ func init() {
 type SessionMap struct {
 	rw       sync.Mutex
 	sessions map[string]*Session
+
+	// scheduler shared by the validations of every session
+	scheduler *greenlight.Scheduler
 }
 
 func (s *SessionMap) Get(id string) *Session {
@@ -42,6 +46,7 @@ func (s *SessionMap) New() (*Session, error) {
 		Created:     time.Now(),
 		fileContext: NewFileContext(context.Background()),
 		Status:      "created",
+		scheduler:   s.scheduler,
 	}
 
 	s.sessions[id] = session
@@ -58,8 +63,10 @@ type Session struct {
 	Profile  *Profile     `json:"profile"`
 	XSDFiles []*XSDUpload `json:"xsdFiles"`
 	Results  []*greenlight.ValidationResult
+	Summary  *greenlight.Summary `json:"summary"`
 
-	fileContext *FileContext `json:"-"`
+	fileContext *FileContext          `json:"-"`
+	scheduler   *greenlight.Scheduler `json:"-"`
 }
 
 func (s *Session) NewValidation() (*greenlight.Validation, error) {
@@ -67,6 +74,13 @@ func (s *Session) NewValidation() (*greenlight.Validation, error) {
 	if err != nil {
 		return nil, err
 	}
+	validation.SetRuleTimeout(viper.GetDuration("rule.timeout"))
+	validation.SetScheduler(s.scheduler)
+	validation.SetSourceContext(viper.GetInt("context.lines"), viper.GetInt("context.limit"))
+	validation.SetMaxErrors(viper.GetInt("errors.max"))
+	if s.Profile.MaxErrors > 0 {
+		validation.SetMaxErrors(s.Profile.MaxErrors)
+	}
 
 	s.Results = []*greenlight.ValidationResult{}
 	xsdConfig := s.xsdConfig()
@@ -80,21 +94,18 @@ func (s *Session) NewValidation() (*greenlight.Validation, error) {
 
 		rvs := []*greenlight.RuleValidation{}
 		for _, script := range s.Profile.Scripts {
-			for name, s := range scripts {
-				if name == script.Name {
-					if name == "xsd" {
-						validation.AddScript(s, xsdConfig)
-					} else {
-						validation.AddScript(s, script.Config)
-					}
+			config := script.Config
+			if script.Name == "xsd" {
+				config = xsdConfig
+			}
 
-					rvs = append(rvs, &greenlight.RuleValidation{
-						Name:       script.Name,
-						Valid:      false,
-						ErrorCount: 0,
-						Errors:     []greenlight.TaskError{},
-					})
-				}
+			if addRule(validation, script.Name, config) {
+				rvs = append(rvs, &greenlight.RuleValidation{
+					Name:       script.Name,
+					Valid:      false,
+					ErrorCount: 0,
+					Errors:     []greenlight.TaskError{},
+				})
 			}
 		}
 
@@ -145,6 +156,7 @@ func (s Session) MarshalJSON() ([]byte, error) {
 		"files":    s.fileContext.Find("xml"),
 		"status":   s.Status,
 		"results":  s.Results,
+		"summary":  s.Summary,
 		"profile":  s.Profile,
 		"xsdFiles": s.XSDFiles,
 	}
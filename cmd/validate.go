@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/concreteit/greenlight"
@@ -20,27 +22,38 @@ import (
 
 var (
 	validateCmd = &cobra.Command{
-		Use:   "validate",
-		Short: "Validate NeTEx files",
-		Run:   validate,
+		Use:     "validate",
+		Short:   "Validate NeTEx files",
+		PreRunE: loadScripts,
+		RunE:    validate,
+		// errors are printed by main, and invalid documents are reported
+		// through the exit status only
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 	scripts = map[string]*js.Script{}
 )
 
 func init() {
-	if scriptMap, err := compileBuiltin(); err != nil {
-		log.Fatal(err)
-	} else {
-		scripts = scriptMap
-	}
-
+	validateCmd.Flags().StringP("baseline", "b", "", "Suppress findings already listed in the given baseline file and only report new ones")
+	validateCmd.Flags().BoolP("cache", "", false, "Reuse results of previous validations for unchanged documents, scripts and config")
+	validateCmd.Flags().StringP("cache-dir", "", "", "Set location of the validation result cache (see \"greenlight cache dir\")")
 	validateCmd.Flags().StringP("input", "i", "", "XML file, dir or archive to validate")
+	validateCmd.Flags().StringSliceP("lib-path", "", []string{}, "Set paths to resolve modules required by name from (e.g. require(\"refs\"))")
 	validateCmd.Flags().StringP("log-level", "l", "debug", "Set level of log output (one of \"trace\", \"debug\", \"info\", \"warn\", \"error\")")
+	validateCmd.Flags().IntP("max-errors", "", greenlight.DefaultMaxErrors, "Set how many findings to report per rule and document, the rest is only counted (if not set, \"maxErrors\" of a profile is used instead; \"maxErrors\" of a profile script config overrides it)")
 	validateCmd.Flags().StringP("output", "o", "pretty", "Set which output format to use (one of \"json\", \"xml\", \"csv\", \"pretty\"")
 	validateCmd.Flags().StringP("profile", "p", "", "Set path of validation profile (note: flags 'rules' and 'schema' is ignored)")
+	validateCmd.Flags().DurationP("rule-timeout", "", 0, "Abort a single rule running on a single document after the given duration (can be overridden with \"timeout\" in a profile script config)")
 	validateCmd.Flags().StringSliceP("rules", "r", []string{}, "Set which validation rules to run (defaults to all inside the builtin dir)")
 	validateCmd.Flags().StringP("schema", "s", "netex@1.2-nc", "Which xsd schema to use (supported \"netex@1.2\", \"netex@1.2-nc\", \"epip@1.1.2\", \"epip@1.1.2-nc\")")
-	validateCmd.Flags().BoolP("silent", "", false, "Running in silent will only output the result in a boolean fashion")
+	validateCmd.Flags().BoolP("silent", "", false, "Running in silent will only report the result through the exit status (non-zero if any document is invalid)")
+	validateCmd.Flags().BoolP("summary", "", true, "Include a summary of the validation in the output (with --summary=false json output is a bare array of results)")
+	validateCmd.Flags().IntP("source-context", "", 0, "Include the given number of source lines before and after each finding (0 disables)")
+	validateCmd.Flags().IntP("source-context-limit", "", greenlight.DefaultSourceContextLimit, "Set how many findings per rule and document include a source context")
+	validateCmd.Flags().DurationP("timeout", "t", 0, "Abort the validation after the given duration (e.g. \"30s\", \"5m\"), partial results are marked as cancelled")
+	validateCmd.Flags().IntP("workers", "w", 0, "Set how many documents, rules and rule workers to run at the same time in total (defaults to the number of CPUs)")
+	validateCmd.Flags().StringP("write-baseline", "", "", "Write every finding of the validation to the given baseline file")
 
 	// read properties from environment
 	viper.SetEnvPrefix("GREENLIGHT")
@@ -48,13 +61,25 @@ func init() {
 	viper.AutomaticEnv()
 
 	// bind from cli input
+	viper.BindPFlag("baseline.path", validateCmd.Flags().Lookup("baseline"))
+	viper.BindPFlag("baseline.write", validateCmd.Flags().Lookup("write-baseline"))
+	viper.BindPFlag("cache.enabled", validateCmd.Flags().Lookup("cache"))
+	viper.BindPFlag("cache.dir", validateCmd.Flags().Lookup("cache-dir"))
 	viper.BindPFlag("input", validateCmd.Flags().Lookup("input"))
+	viper.BindPFlag("lib.path", validateCmd.Flags().Lookup("lib-path"))
 	viper.BindPFlag("log.level", validateCmd.Flags().Lookup("log-level"))
+	viper.BindPFlag("errors.max", validateCmd.Flags().Lookup("max-errors"))
 	viper.BindPFlag("output", validateCmd.Flags().Lookup("output"))
 	viper.BindPFlag("profile", validateCmd.Flags().Lookup("profile"))
+	viper.BindPFlag("rule.timeout", validateCmd.Flags().Lookup("rule-timeout"))
 	viper.BindPFlag("rules", validateCmd.Flags().Lookup("rules"))
 	viper.BindPFlag("schema", validateCmd.Flags().Lookup("schema"))
 	viper.BindPFlag("silent", validateCmd.Flags().Lookup("silent"))
+	viper.BindPFlag("summary", validateCmd.Flags().Lookup("summary"))
+	viper.BindPFlag("context.lines", validateCmd.Flags().Lookup("source-context"))
+	viper.BindPFlag("context.limit", validateCmd.Flags().Lookup("source-context-limit"))
+	viper.BindPFlag("timeout", validateCmd.Flags().Lookup("timeout"))
+	viper.BindPFlag("workers", validateCmd.Flags().Lookup("workers"))
 
 	rootCmd.AddCommand(validateCmd)
 }
@@ -113,6 +138,25 @@ func createValidation(input string) (*greenlight.Validation, *FileContext, error
 	if err != nil {
 		return nil, nil, err
 	}
+	validation.SetRuleTimeout(viper.GetDuration("rule.timeout"))
+	validation.SetScheduler(greenlight.NewScheduler(viper.GetInt("workers")))
+	validation.SetSourceContext(viper.GetInt("context.lines"), viper.GetInt("context.limit"))
+	validation.SetMaxErrors(viper.GetInt("errors.max"))
+
+	if viper.GetBool("cache.enabled") {
+		dir := viper.GetString("cache.dir")
+		if dir == "" {
+			dir = defaultCacheDir()
+		}
+
+		cache, err := greenlight.NewCache(dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		validation.SetCache(cache)
+
+		log.Debugf("using validation result cache at '%s'", dir)
+	}
 
 	if path := viper.GetString("profile"); path != "" {
 		profile, err := OpenProfile(path)
@@ -122,12 +166,13 @@ func createValidation(input string) (*greenlight.Validation, *FileContext, error
 
 		log.Debugf("validating using profile at '%s'", path)
 
+		// an explicitly set --max-errors wins over the profile
+		if profile.MaxErrors > 0 && !viper.IsSet("errors.max") {
+			validation.SetMaxErrors(profile.MaxErrors)
+		}
+
 		for _, script := range profile.Scripts {
-			for name, s := range scripts {
-				if name == script.Name {
-					validation.AddScript(s, script.Config)
-				}
-			}
+			addRule(validation, script.Name, script.Config)
 		}
 	} else {
 		schema := viper.GetString("schema")
@@ -141,23 +186,16 @@ func createValidation(input string) (*greenlight.Validation, *FileContext, error
 
 		rules := viper.GetStringSlice("rules")
 		if rules == nil || len(rules) == 0 {
-			for name, script := range scripts {
+			for _, name := range ruleNames() {
 				if name == "xsd" {
 					continue
 				}
-				validation.AddScript(script, nil)
+				addRule(validation, name, nil)
 			}
 		} else {
 			for _, r := range rules {
-				exist := false
-				for name, script := range scripts {
-					if r == name {
-						validation.AddScript(script, nil)
-						exist = true
-					}
-				}
-				if !exist {
-					log.Fatalf("unable to find rule with the name '%s'", r)
+				if !addRule(validation, r, nil) {
+					return nil, nil, fmt.Errorf("unable to find rule with the name '%s'", r)
 				}
 			}
 		}
@@ -206,14 +244,14 @@ func createValidation(input string) (*greenlight.Validation, *FileContext, error
 	return validation, fileContext, nil
 }
 
-func validate(cmd *cobra.Command, args []string) {
+func validate(cmd *cobra.Command, args []string) error {
 	silent := viper.GetBool("silent")
 	if silent {
 		log.SetLevel(log.FatalLevel)
 	} else {
 		level, err := log.ParseLevel(viper.GetString("log.level"))
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		log.SetLevel(level)
@@ -225,40 +263,85 @@ func validate(cmd *cobra.Command, args []string) {
 
 	input := viper.GetString("input")
 	if input == "" {
-		log.Fatal("no input provided")
+		return fmt.Errorf("no input provided")
 	}
 
 	validation, fileContext, err := createValidation(internal.DirExpand(input))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer fileContext.Close()
 
-	res, err := validation.Validate(context.Background())
-	if err != nil {
-		log.Fatal(err)
+	ctx := context.Background()
+	if timeout := viper.GetDuration("timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	if silent {
-		for _, r := range res {
-			if !r.Valid {
-				return
-			}
+	res, err := validateWithProgress(ctx, validation, fileContext)
+	if err != nil && !internal.IsCancelled(err) {
+		return err
+	} else if err != nil {
+		log.Warnf("validation was cancelled (%s), results are partial", err)
+	}
+
+	if path := viper.GetString("baseline.write"); path != "" {
+		baseline := greenlight.NewBaseline(res)
+		if err := baseline.Save(path); err != nil {
+			return err
+		}
+
+		log.Infof("wrote %d findings to baseline at '%s'", len(baseline.Findings), path)
+	}
+
+	if path := viper.GetString("baseline.path"); path != "" {
+		baseline, err := greenlight.OpenBaseline(path)
+		if err != nil {
+			return err
+		}
+
+		n := baseline.Apply(res)
+		log.Infof("suppressed %d known findings using baseline at '%s'", n, path)
+	}
+
+	// only findings with severity "error" affects validity, any invalid
+	// document makes the command exit with a non-zero status (see main)
+	var status error
+	for _, r := range res {
+		if !r.Valid {
+			status = errInvalidDocuments
 		}
-		return
 	}
 
+	if silent {
+		return status
+	}
+
+	// without a summary the results are output the way they were before the
+	// summary was added, i.e. a bare array of results in json
+	var summary *greenlight.Summary
+	if viper.GetBool("summary") {
+		summary = greenlight.NewSummary(res)
+	}
 	output := viper.GetString("output")
 	switch output {
 	case "json":
-		buf, err := json.MarshalIndent(res, "", " ")
+		var v interface{} = res
+		if summary != nil {
+			v = ValidationResults{
+				Summary:          summary,
+				ValidationResult: res,
+			}
+		}
+		buf, err := json.MarshalIndent(v, "", " ")
 		if err != nil {
-			log.Fatal(err)
-		} else {
-			fmt.Println(string(buf))
+			return err
 		}
+		fmt.Println(string(buf))
 	case "xml":
 		buf, err := xml.MarshalIndent(ValidationResults{
+			Summary:          summary,
 			ValidationResult: res,
 		}, "", " ")
 		if err != nil {
@@ -267,16 +350,23 @@ func validate(cmd *cobra.Command, args []string) {
 			fmt.Println(string(buf))
 		}
 	case "csv":
+		w := csv.NewWriter(os.Stdout)
 		for i, result := range res {
-			rows := result.CsvRecords(i == 0)
-			for _, row := range rows {
-				fmt.Println(strings.Join(row, ","))
+			if err := w.WriteAll(result.CsvRecords(i == 0)); err != nil {
+				return err
+			}
+		}
+
+		// the summary follows the results as a separate table
+		if summary != nil {
+			if err := w.WriteAll(append([][]string{{}}, summary.CsvRecords(true)...)); err != nil {
+				return err
 			}
 		}
 	case "pretty":
 		tw, _, err := terminal.GetSize(0)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
 
 		for _, r := range res {
@@ -304,11 +394,78 @@ func validate(cmd *cobra.Command, args []string) {
 			}
 			w.AppendSeparator()
 			w.AppendFooter(table.Row{"", "", "valid", r.Valid})
+			if r.SuppressedCount > 0 {
+				w.AppendFooter(table.Row{"", "", "suppressed", r.SuppressedCount})
+			}
 			w.Render()
 		}
+		if summary == nil {
+			break
+		}
+
+		w := table.NewWriter()
+		w.SetAllowedRowLength(tw)
+		w.SetStyle(table.StyleLight)
+		w.SetOutputMirror(os.Stdout)
+		w.SetTitle("summary")
+		for i, row := range summary.CsvRecords(true) {
+			r := table.Row{}
+			for _, v := range row {
+				r = append(r, v)
+			}
+			if i == 0 {
+				w.AppendHeader(r)
+			} else {
+				w.AppendRow(r)
+			}
+		}
+		w.Render()
+	}
+
+	return status
+}
+
+// validateWithProgress runs the validation, logging each rule and document as
+// soon as it is complete. The results are returned in the order of the files
+// in fileContext.
+func validateWithProgress(ctx context.Context, validation *greenlight.Validation, fileContext *FileContext) ([]*greenlight.ValidationResult, error) {
+	var err error
+	res := []*greenlight.ValidationResult{}
+	for r := range validation.ValidateStream(ctx) {
+		switch r.Type {
+		case greenlight.StreamResultTypeRule:
+			log.WithFields(log.Fields{
+				"document": r.Document,
+				"rule":     r.Rule.Name,
+				"status":   r.Rule.Status,
+				"valid":    r.Rule.Valid,
+			}).Debug("rule validation complete")
+		case greenlight.StreamResultTypeDocument:
+			log.WithFields(log.Fields{
+				"document": r.Document,
+				"valid":    r.Result.Valid,
+			}).Info("document validation complete")
+			res = append(res, r.Result)
+		case greenlight.StreamResultTypeError:
+			err = r.Err
+		}
 	}
+
+	// the stream delivers every document as soon as it is complete, which
+	// isn't necessarily the order the documents were added in (only the
+	// results returned by Validate are ordered by the queue)
+	order := map[string]int{}
+	for i, file := range fileContext.Find("xml") {
+		order[file.Name] = i
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return order[res[i].Name] < order[res[j].Name]
+	})
+
+	return res, err
 }
 
 type ValidationResults struct {
-	ValidationResult []*greenlight.ValidationResult `xml:"ValidationResult"`
+	Summary          *greenlight.Summary            `json:"summary" xml:"Summary,omitempty"`
+	ValidationResult []*greenlight.ValidationResult `json:"results" xml:"ValidationResult"`
 }
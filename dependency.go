@@ -0,0 +1,151 @@
+package greenlight
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/concreteit/greenlight/js"
+)
+
+// scriptDependencies resolves the dependencies of a script from its config.
+// Scripts listed in "requires" must run, and pass, before the script is run
+// while scripts listed in "after" only must have run before it.
+func scriptDependencies(cfg map[string]interface{}) ([]string, []string, error) {
+	if cfg == nil {
+		return nil, nil, nil
+	}
+
+	requires, err := stringSlice(cfg["requires"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid value for 'requires': %w", err)
+	}
+
+	after, err := stringSlice(cfg["after"])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid value for 'after': %w", err)
+	}
+
+	return requires, after, nil
+}
+
+// scriptLevels orders the scripts of the given scope into levels, every
+// script in a level only depends on scripts in earlier levels. Collection
+// scripts are run before document scripts, hence a document script may depend
+// on a collection script but not the other way around.
+func (v *Validation) scriptLevels(scope string) ([][]ScriptEnv, error) {
+	scripts := map[string]ScriptEnv{}
+	for name, env := range v.scripts {
+		s, err := scriptScope(env)
+		if err != nil {
+			return nil, fmt.Errorf("script '%s' has an invalid scope: %w", name, err)
+		}
+		if s == scope {
+			scripts[name] = env
+		}
+	}
+
+	deps := map[string][]string{}
+	for name, env := range scripts {
+		requires, after, err := scriptDependencies(env.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("script '%s' has %w", name, err)
+		}
+
+		for _, dep := range append(requires, after...) {
+			if dep == name {
+				return nil, fmt.Errorf("script '%s' depends on itself", name)
+			}
+			if _, ok := scripts[dep]; ok {
+				deps[name] = append(deps[name], dep)
+			} else if _, ok := v.scripts[dep]; ok && scope == js.ScopeCollection {
+				return nil, fmt.Errorf("collection script '%s' can't depend on document script '%s'", name, dep)
+			}
+		}
+	}
+
+	levels := [][]ScriptEnv{}
+	done := map[string]bool{}
+	for len(done) < len(scripts) {
+		names := []string{}
+		for name := range scripts {
+			if done[name] {
+				continue
+			}
+
+			ready := true
+			for _, dep := range deps[name] {
+				if !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			pending := []string{}
+			for name := range scripts {
+				if !done[name] {
+					pending = append(pending, name)
+				}
+			}
+			sort.Strings(pending)
+
+			return nil, fmt.Errorf("cyclic dependency between scripts '%s'", strings.Join(pending, "', '"))
+		}
+
+		sort.Strings(names)
+		level := []ScriptEnv{}
+		for _, name := range names {
+			done[name] = true
+			level = append(level, scripts[name])
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// skipReason returns the reason to why a script should be skipped given the
+// result of previously run scripts, or an empty string if it should run
+func skipReason(env ScriptEnv, scripts map[string]ScriptEnv, done map[string]*RuleValidation) string {
+	requires, _, _ := scriptDependencies(env.cfg)
+	for _, dep := range requires {
+		if _, ok := scripts[dep]; !ok {
+			return fmt.Sprintf("required rule '%s' is not part of the validation", dep)
+		}
+
+		rv := done[dep]
+		if rv == nil || rv.Status != RuleStatusComplete || !rv.Valid {
+			return fmt.Sprintf("required rule '%s' did not pass", dep)
+		}
+	}
+
+	return ""
+}
+
+func stringSlice(v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{t}, nil
+	case []string:
+		return t, nil
+	case []interface{}:
+		res := []string{}
+		for _, vs := range t {
+			s, ok := vs.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected '%v' to be a string", vs)
+			}
+			res = append(res, s)
+		}
+		return res, nil
+	}
+
+	return nil, fmt.Errorf("expected '%v' to be a string or a list of strings", v)
+}
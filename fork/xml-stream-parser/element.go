@@ -1,5 +1,10 @@
 package xmlparser
 
+import (
+	"fmt"
+	"strings"
+)
+
 type XMLElement struct {
 	childs     []*XMLElement
 	parent     *XMLElement
@@ -7,13 +12,23 @@ type XMLElement struct {
 	localName  string
 	prefix     string
 	elementMap map[string][]*XMLElement
+	commented  []*XMLElement
 
 	Line      int
+	Column    int
+	EndLine   int
 	Name      string
 	Attrs     map[string]string
 	InnerText string
 	Childs    map[string][]XMLElement
 	Err       error
+	Comments  []XMLComment
+}
+
+// XMLComment is a comment preceding an element
+type XMLComment struct {
+	Line int
+	Text string
 }
 
 type xmlAttr struct {
@@ -27,6 +42,43 @@ func (n *XMLElement) SelectElements(exp string) ([]*XMLElement, error) { return
 // SelectElement finds child elements with the specified xpath expression.
 func (n *XMLElement) SelectElement(exp string) (*XMLElement, error) { return findOne(n, exp) }
 
+// Parent returns the parent element, nil for the root element
+func (n *XMLElement) Parent() *XMLElement { return n.parent }
+
+// Children returns the child elements in document order
+func (n *XMLElement) Children() []*XMLElement { return n.childs }
+
+// Path returns the absolute location path of the element, e.g.
+// /PublicationDelivery[1]/dataObjects[1]/CompositeFrame[2]
+func (n *XMLElement) Path() string {
+	steps := []string{}
+	for el := n; el != nil; el = el.parent {
+		pos := 1
+		if el.parent != nil {
+			for _, c := range el.parent.childs {
+				if c == el {
+					break
+				}
+				if c.Name == el.Name {
+					pos++
+				}
+			}
+		}
+		steps = append(steps, fmt.Sprintf("%s[%d]", el.Name, pos))
+	}
+
+	var sb strings.Builder
+	for i := len(steps) - 1; i >= 0; i-- {
+		sb.WriteString("/")
+		sb.WriteString(steps[i])
+	}
+
+	return sb.String()
+}
+
+// CommentedElements returns every element preceded by one or more comments
+func (n *XMLElement) CommentedElements() []*XMLElement { return n.commented }
+
 func (n *XMLElement) FirstChild() *XMLElement {
 	if len(n.childs) > 0 {
 		return n.childs[0]
@@ -2,16 +2,21 @@ package xmlparser
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"strings"
 )
 
 type XMLParser struct {
 	line       int
+	col        int
+	prevCol    int
 	reader     *bufio.Reader
 	scratch    *scratch
 	scratch2   *scratch
-	elementMap map[string]map[*XMLElement]bool
+	elementMap map[string][]*XMLElement // elements by name, in document order
+	comments   []XMLComment
+	commented  []*XMLElement
 }
 
 func Parse(reader *bufio.Reader) (*XMLElement, error) {
@@ -19,7 +24,7 @@ func Parse(reader *bufio.Reader) (*XMLElement, error) {
 		reader:     reader,
 		scratch:    &scratch{data: make([]byte, 1024)},
 		scratch2:   &scratch{data: make([]byte, 1024)},
-		elementMap: make(map[string]map[*XMLElement]bool),
+		elementMap: make(map[string][]*XMLElement),
 	}
 
 	return x.Parse()
@@ -59,14 +64,9 @@ func (x *XMLParser) Parse() (*XMLElement, error) {
 			}
 
 			ele := x.getElementTree(element)
-			ele.elementMap = make(map[string][]*XMLElement)
-			for k, v := range x.elementMap {
-				eles := []*XMLElement{}
-				for el, _ := range v {
-					eles = append(eles, el)
-				}
-				ele.elementMap[k] = eles
-			}
+			ele.EndLine = x.line + 1
+			ele.commented = x.commented
+			ele.elementMap = x.elementMap
 
 			return ele, nil
 		}
@@ -109,6 +109,8 @@ func (x *XMLParser) getElementTree(result *XMLElement) *XMLElement {
 				result.Err = err
 				return result
 			} else if next == '/' { // close tag
+				// comments not followed by an element are dropped
+				x.comments = nil
 				if tag, err := x.closeTagName(); err != nil {
 					result.Err = err
 					return result
@@ -130,6 +132,7 @@ func (x *XMLParser) getElementTree(result *XMLElement) *XMLElement {
 			if !tagClosed {
 				element = x.getElementTree(element)
 			}
+			element.EndLine = x.line + 1
 
 			element.parent = result
 
@@ -156,16 +159,19 @@ func (x *XMLParser) startElement() (*XMLElement, bool, error) {
 
 	var prev byte
 	var result = &XMLElement{
-		Line: x.line + 1,
+		Line:     x.line + 1,
+		Column:   x.col,
+		Comments: x.comments,
+	}
+	if x.comments != nil {
+		x.commented = append(x.commented, result)
+		x.comments = nil
 	}
 	defer func() {
 		if result == nil || result.Name == "" {
 			return
 		}
-		if x.elementMap[result.Name] == nil {
-			x.elementMap[result.Name] = make(map[*XMLElement]bool)
-		}
-		x.elementMap[result.Name][result] = true
+		x.elementMap[result.Name] = append(x.elementMap[result.Name], result)
 	}()
 
 	for {
@@ -264,7 +270,9 @@ search_close_tag:
 	}
 }
 
+// isComment consumes a comment, keeping it as a marker for the next element
 func (x *XMLParser) isComment() (bool, error) {
+	line := x.line + 1
 	if c, err := x.readByte(); err != nil {
 		return false, err
 	} else if c != '!' {
@@ -293,6 +301,7 @@ func (x *XMLParser) isComment() (bool, error) {
 		if err != nil {
 			return false, err
 		} else if c == '>' && len(x.scratch.bytes()) > 1 && x.scratch.bytes()[len(x.scratch.bytes())-1] == '-' && x.scratch.bytes()[len(x.scratch.bytes())-2] == '-' {
+			x.addComment(line, x.scratch.bytes()[:len(x.scratch.bytes())-2])
 			return true, nil
 		}
 
@@ -300,6 +309,13 @@ func (x *XMLParser) isComment() (bool, error) {
 	}
 }
 
+func (x *XMLParser) addComment(line int, data []byte) {
+	x.comments = append(x.comments, XMLComment{
+		Line: line,
+		Text: strings.TrimSpace(string(data)),
+	})
+}
+
 func (x *XMLParser) isCDATA() (bool, []byte, error) {
 	b, err := x.reader.Peek(2)
 	if err != nil {
@@ -445,6 +461,9 @@ skipComment:
 			return err
 		}
 		if c == '>' && len(x.scratch.bytes()) > 1 && x.scratch.bytes()[len(x.scratch.bytes())-1] == '-' && x.scratch.bytes()[len(x.scratch.bytes())-2] == '-' {
+			// the comment starts as many lines back as it spans
+			line := x.line + 1 - bytes.Count(x.scratch.bytes(), []byte{'\n'})
+			x.addComment(line, x.scratch.bytes()[:len(x.scratch.bytes())-2])
 			goto scanDeclarations
 		}
 
@@ -491,6 +510,10 @@ func (x *XMLParser) readByte() (byte, error) {
 	}
 	if by == '\n' {
 		x.line++
+		x.prevCol = x.col
+		x.col = 0
+	} else {
+		x.col++
 	}
 	return by, nil
 
@@ -501,6 +524,12 @@ func (x *XMLParser) unreadByte() error {
 	if err != nil {
 		return err
 	}
+	if x.col == 0 {
+		x.line--
+		x.col = x.prevCol
+	} else {
+		x.col--
+	}
 	return nil
 }
 
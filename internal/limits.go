@@ -0,0 +1,18 @@
+package internal
+
+import "context"
+
+type maxErrorsKey struct{}
+
+// WithMaxErrors returns a copy of ctx in which rules store at most n findings,
+// the others are only counted
+func WithMaxErrors(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, maxErrorsKey{}, n)
+}
+
+// MaxErrorsFrom returns the number of findings rules store at most in ctx,
+// zero if there is no limit
+func MaxErrorsFrom(ctx context.Context) int {
+	n, _ := ctx.Value(maxErrorsKey{}).(int)
+	return n
+}
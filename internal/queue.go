@@ -1,40 +1,134 @@
 package internal
 
 import (
-	"runtime"
-
-	"github.com/sourcegraph/conc/pool"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 )
 
-type Task func(int) Result
+// Task is a unit of work run by a Queue, id is the position (starting at 1) of
+// the task in the queue. Queues run from within a task must be given ctx, so
+// that they borrow from the same scheduler budget as the task.
+type Task[T any] func(ctx context.Context, id int) (T, error)
+
+// TaskResult is the outcome of a single task
+type TaskResult[T any] struct {
+	Value T
+	Err   error
+}
+
+// Queue is an ordered worker pool, the results of its tasks are returned in
+// the order the tasks were added regardless of the order they complete in
+type Queue[T any] struct {
+	tasks []Task[T]
+}
 
-type Queue struct {
-	tasks []Task
+func NewQueue[T any]() *Queue[T] {
+	return &Queue[T]{
+		tasks: []Task[T]{},
+	}
 }
 
-func (q *Queue) Add(task Task) {
+func (q *Queue[T]) Add(task Task[T]) {
 	q.tasks = append(q.tasks, task)
 }
 
-func (q *Queue) Run() []Result {
-	wg := pool.New().WithMaxGoroutines(runtime.GOMAXPROCS(0))
-	res := []Result{}
-	for i, t := range q.tasks {
-		task := t
-		id := i + 1
-		wg.Go(func() {
-			res = append(res, task(id))
-		})
+// Run executes all queued tasks and returns their results in submission order.
+// Tasks are scheduled on the scheduler of ctx (see WithScheduler). When run
+// from within a task, the calling goroutine runs tasks of the queue itself
+// using the slot of the task, so nested queues never wait for a slot held by
+// their parent.
+//
+// Tasks that have not yet been started when ctx is done are skipped and
+// reported as a result holding the context error. A panicking task is reported
+// as a result holding the recovered value as error.
+func (q *Queue[T]) Run(ctx context.Context) []TaskResult[T] {
+	tasks := q.tasks
+	q.tasks = []Task[T]{}
+
+	res := make([]TaskResult[T], len(tasks))
+	if len(tasks) == 0 {
+		return res
+	}
+
+	s := SchedulerFrom(ctx)
+	inline := s.holdsSlot(ctx)
+	tctx := context.WithValue(ctx, slotKey{}, s)
+
+	next := int64(-1)
+	claim := func() (int, bool) {
+		i := int(atomic.AddInt64(&next, 1))
+		return i, i < len(tasks)
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(tasks))
+	done := make(chan struct{})
+	defer close(done)
+
+	helpers := len(tasks)
+	if inline {
+		helpers--
+	}
+	for h := 0; h < helpers && h < s.Workers(); h++ {
+		go func() {
+			for {
+				select {
+				case s.slots <- struct{}{}:
+				case <-ctx.Done():
+					for i, ok := claim(); ok; i, ok = claim() {
+						res[i] = TaskResult[T]{Err: ctx.Err()}
+						wg.Done()
+					}
+					return
+				case <-done:
+					return
+				}
+
+				i, ok := claim()
+				if ok {
+					res[i] = runTask(tctx, i+1, tasks[i])
+				}
+				<-s.slots
+				if !ok {
+					return
+				}
+				wg.Done()
+			}
+		}()
 	}
 
-	q.tasks = []Task{}
+	if inline {
+		for i, ok := claim(); ok; i, ok = claim() {
+			res[i] = runTask(tctx, i+1, tasks[i])
+			wg.Done()
+		}
+	}
 	wg.Wait()
 
 	return res
 }
 
-func NewQueue() *Queue {
-	return &Queue{
-		tasks: []Task{},
+func runTask[T any](ctx context.Context, id int, task Task[T]) (res TaskResult[T]) {
+	if err := ctx.Err(); err != nil {
+		res.Err = err
+		return res
 	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			res = TaskResult[T]{Err: fmt.Errorf("task %d panicked: %v", id, r)}
+		}
+	}()
+
+	res.Value, res.Err = task(ctx, id)
+
+	return res
+}
+
+// IsCancelled reports whether err is caused by a cancelled or expired context
+func IsCancelled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
 }
@@ -0,0 +1,214 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runWithin fails the test if fn doesn't return within d, e.g. on deadlock
+func runWithin(t *testing.T, d time.Duration, fn func()) {
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(d):
+		t.Fatalf("did not complete within %s", d)
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	ctx := WithScheduler(context.Background(), NewScheduler(4))
+	queue := NewQueue[int]()
+	for i := 0; i < 20; i++ {
+		i := i
+		queue.Add(func(ctx context.Context, id int) (int, error) {
+			// later tasks complete first
+			time.Sleep(time.Duration(20-i) * time.Millisecond)
+			return id, nil
+		})
+	}
+
+	res := queue.Run(ctx)
+	if len(res) != 20 {
+		t.Fatalf("got %d results, want 20", len(res))
+	}
+	for i, r := range res {
+		if r.Err != nil {
+			t.Fatalf("task %d: unexpected error %s", i+1, r.Err)
+		}
+		if r.Value != i+1 {
+			t.Errorf("result %d: got value of task %d", i+1, r.Value)
+		}
+	}
+}
+
+func TestQueueWorkers(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		ctx := WithScheduler(context.Background(), NewScheduler(workers))
+		running, max := int64(0), int64(0)
+		queue := NewQueue[struct{}]()
+		for i := 0; i < 12; i++ {
+			queue.Add(func(ctx context.Context, id int) (struct{}, error) {
+				n := atomic.AddInt64(&running, 1)
+				for m := atomic.LoadInt64(&max); n > m && !atomic.CompareAndSwapInt64(&max, m, n); m = atomic.LoadInt64(&max) {
+				}
+				time.Sleep(2 * time.Millisecond)
+				atomic.AddInt64(&running, -1)
+				return struct{}{}, nil
+			})
+		}
+
+		queue.Run(ctx)
+		if max > int64(workers) {
+			t.Errorf("workers %d: got %d tasks running at the same time", workers, max)
+		}
+	}
+}
+
+func TestQueuePanic(t *testing.T) {
+	queue := NewQueue[string]()
+	queue.Add(func(ctx context.Context, id int) (string, error) { return "a", nil })
+	queue.Add(func(ctx context.Context, id int) (string, error) { panic("boom") })
+	queue.Add(func(ctx context.Context, id int) (string, error) { return "c", nil })
+
+	res := queue.Run(context.Background())
+	if res[0].Value != "a" || res[2].Value != "c" {
+		t.Errorf("got values %q and %q, want \"a\" and \"c\"", res[0].Value, res[2].Value)
+	}
+	if res[1].Err == nil || !strings.Contains(res[1].Err.Error(), "task 2 panicked: boom") {
+		t.Errorf("got error %v, want the panic of task 2", res[1].Err)
+	}
+}
+
+func TestQueueCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithScheduler(context.Background(), NewScheduler(1)))
+	defer cancel()
+
+	started := int64(0)
+	queue := NewQueue[int]()
+	for i := 0; i < 10; i++ {
+		queue.Add(func(ctx context.Context, id int) (int, error) {
+			atomic.AddInt64(&started, 1)
+			if id == 2 {
+				cancel()
+			}
+			return id, nil
+		})
+	}
+
+	var res []TaskResult[int]
+	runWithin(t, time.Second, func() { res = queue.Run(ctx) })
+
+	if n := atomic.LoadInt64(&started); n == 10 {
+		t.Error("expected tasks queued after the cancellation to be skipped")
+	}
+	for i, r := range res {
+		if r.Err == nil && r.Value != i+1 {
+			t.Errorf("result %d: got value %d", i+1, r.Value)
+		}
+		if r.Err != nil && !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("result %d: got error %s, want context.Canceled", i+1, r.Err)
+		}
+	}
+	if !IsCancelled(res[len(res)-1].Err) {
+		t.Errorf("last result: got error %v, want the context error", res[len(res)-1].Err)
+	}
+}
+
+func TestQueueCancelledBeforeRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queue := NewQueue[int]()
+	queue.Add(func(ctx context.Context, id int) (int, error) {
+		t.Error("expected no task to run")
+		return 0, nil
+	})
+
+	for _, r := range queue.Run(ctx) {
+		if !IsCancelled(r.Err) {
+			t.Errorf("got error %v, want the context error", r.Err)
+		}
+	}
+}
+
+func TestQueueNested(t *testing.T) {
+	// every slot of the scheduler is held by an outer task, inner queues must
+	// run on the slot of their parent rather than wait for a free one
+	for _, workers := range []int{1, 2} {
+		ctx := WithScheduler(context.Background(), NewScheduler(workers))
+		outer := NewQueue[int]()
+		for i := 0; i < 4; i++ {
+			outer.Add(func(ctx context.Context, id int) (int, error) {
+				inner := NewQueue[int]()
+				for j := 0; j < 3; j++ {
+					inner.Add(func(ctx context.Context, id int) (int, error) {
+						nested := NewQueue[int]()
+						nested.Add(func(ctx context.Context, id int) (int, error) { return 1, nil })
+						return nested.Run(ctx)[0].Value, nil
+					})
+				}
+
+				sum := 0
+				for _, r := range inner.Run(ctx) {
+					if r.Err != nil {
+						return 0, r.Err
+					}
+					sum += r.Value
+				}
+				return sum, nil
+			})
+		}
+
+		var res []TaskResult[int]
+		runWithin(t, 5*time.Second, func() { res = outer.Run(ctx) })
+		for i, r := range res {
+			if r.Err != nil || r.Value != 3 {
+				t.Errorf("workers %d, result %d: got (%d, %v), want (3, nil)", workers, i+1, r.Value, r.Err)
+			}
+		}
+	}
+}
+
+func TestQueueEmpty(t *testing.T) {
+	if res := NewQueue[int]().Run(context.Background()); len(res) != 0 {
+		t.Errorf("got %d results, want none", len(res))
+	}
+}
+
+func TestSchedulerAcquire(t *testing.T) {
+	s := NewScheduler(1)
+	ctx := WithScheduler(context.Background(), s)
+	release := s.Acquire()
+
+	started := atomic.Bool{}
+	runWithin(t, time.Second, func() {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			if started.Load() {
+				t.Error("expected the task to wait for the acquired slot")
+			}
+			release()
+		}()
+
+		queue := NewQueue[int]()
+		queue.Add(func(ctx context.Context, id int) (int, error) {
+			started.Store(true)
+			return id, nil
+		})
+		queue.Run(ctx)
+	})
+	if !started.Load() {
+		t.Error("expected the task to run once the slot is released")
+	}
+}
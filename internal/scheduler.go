@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"context"
+	"runtime"
+)
+
+var defaultScheduler = NewScheduler(0)
+
+type (
+	schedulerKey struct{}
+	slotKey      struct{}
+)
+
+// Scheduler limits the number of tasks running at the same time across every
+// queue run with it, including queues run from within a task
+type Scheduler struct {
+	slots chan struct{}
+}
+
+// NewScheduler creates a scheduler running at most workers tasks at the same
+// time, GOMAXPROCS if workers is zero or less
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	return &Scheduler{
+		slots: make(chan struct{}, workers),
+	}
+}
+
+// Workers returns the number of tasks the scheduler runs at the same time
+func (s *Scheduler) Workers() int { return cap(s.slots) }
+
+// holdsSlot reports whether ctx belongs to a task running on s
+func (s *Scheduler) holdsSlot(ctx context.Context) bool {
+	v, _ := ctx.Value(slotKey{}).(*Scheduler)
+	return v == s
+}
+
+// WithScheduler returns a copy of ctx in which queues are run with s
+func WithScheduler(ctx context.Context, s *Scheduler) context.Context {
+	return context.WithValue(ctx, schedulerKey{}, s)
+}
+
+// SchedulerFrom returns the scheduler of ctx, or a scheduler shared by the
+// whole process running GOMAXPROCS tasks at the same time if none is set
+func SchedulerFrom(ctx context.Context) *Scheduler {
+	if s, ok := ctx.Value(schedulerKey{}).(*Scheduler); ok && s != nil {
+		return s
+	}
+
+	return defaultScheduler
+}
+
+// Acquire takes a slot of the scheduler once one is free, the returned
+// function releases it. It is meant for work left running once the task that
+// started it has returned (e.g. a libxml run which can't be stopped), so that
+// it keeps counting against the budget of the scheduler.
+func (s *Scheduler) Acquire() func() {
+	s.slots <- struct{}{}
+
+	return func() { <-s.slots }
+}
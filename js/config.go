@@ -0,0 +1,286 @@
+package js
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Types of a config option
+const (
+	ConfigTypeNumber  = "number"
+	ConfigTypeInteger = "integer"
+	ConfigTypeString  = "string"
+	ConfigTypeBoolean = "boolean"
+	ConfigTypeArray   = "array"
+)
+
+// ConfigOption describes a single option of a script config
+type ConfigOption struct {
+	Type        string        `json:"type"`
+	Default     interface{}   `json:"default,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Min         *float64      `json:"min,omitempty"`
+	Max         *float64      `json:"max,omitempty"`
+	Description string        `json:"description,omitempty"`
+}
+
+// ConfigSchema describes the options a script accepts in its config, as
+// declared by the script with the variable "configSchema", e.g.
+//
+//	const configSchema = {
+//	  distance: { type: "number", default: 500, min: 0, description: "..." },
+//	};
+type ConfigSchema map[string]ConfigOption
+
+// Keys returns the names of the options in sorted order
+func (s ConfigSchema) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Apply returns a copy of cfg with the default value of every option not set
+// in cfg
+func (s ConfigSchema) Apply(cfg map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range cfg {
+		res[k] = v
+	}
+	for k, opt := range s {
+		if _, ok := res[k]; !ok && opt.Default != nil {
+			res[k] = opt.Default
+		}
+	}
+
+	return res
+}
+
+// Validate checks the values of cfg against the schema, keys listed in ignore
+// are left unchecked. Every invalid or unknown key is reported in the error.
+func (s ConfigSchema) Validate(cfg map[string]interface{}, ignore ...string) error {
+	keys := make([]string, 0, len(cfg))
+	for k := range cfg {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := []string{}
+	for _, k := range keys {
+		if contains(ignore, k) {
+			continue
+		}
+
+		opt, ok := s[k]
+		if !ok {
+			msg := fmt.Sprintf("unknown option '%s'", k)
+			if m := closestKey(k, append(s.Keys(), ignore...)); m != "" {
+				msg += fmt.Sprintf(" (did you mean '%s'?)", m)
+			}
+			msgs = append(msgs, msg)
+		} else if err := opt.validate(cfg[k]); err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid value for '%s': %s", k, err))
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("%s", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
+
+func (o ConfigOption) validate(v interface{}) error {
+	switch o.Type {
+	case ConfigTypeNumber, ConfigTypeInteger:
+		n, ok := configNumber(v)
+		if !ok {
+			return fmt.Errorf("expected %s, got '%v'", article(o.Type), v)
+		}
+		if o.Type == ConfigTypeInteger && n != math.Trunc(n) {
+			return fmt.Errorf("expected an integer, got '%v'", v)
+		}
+		if o.Min != nil && n < *o.Min {
+			return fmt.Errorf("expected a value of at least %v, got '%v'", *o.Min, v)
+		}
+		if o.Max != nil && n > *o.Max {
+			return fmt.Errorf("expected a value of at most %v, got '%v'", *o.Max, v)
+		}
+	case ConfigTypeString:
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("expected a string, got '%v'", v)
+		}
+	case ConfigTypeBoolean:
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("expected a boolean, got '%v'", v)
+		}
+	case ConfigTypeArray:
+		if v == nil || reflect.TypeOf(v).Kind() != reflect.Slice {
+			return fmt.Errorf("expected an array, got '%v'", v)
+		}
+	}
+
+	if len(o.Enum) > 0 {
+		for _, e := range o.Enum {
+			if configEqual(e, v) {
+				return nil
+			}
+		}
+
+		allowed := make([]string, len(o.Enum))
+		for i, e := range o.Enum {
+			allowed[i] = fmt.Sprintf("'%v'", e)
+		}
+		return fmt.Errorf("expected one of %s, got '%v'", strings.Join(allowed, ", "), v)
+	}
+
+	return nil
+}
+
+// parseConfigSchema reads the schema declared by a script, reporting options
+// which are not valid themselves
+func parseConfigSchema(v map[string]interface{}) (ConfigSchema, error) {
+	schema := ConfigSchema{}
+	for k, o := range v {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("option '%s' must be an object", k)
+		}
+
+		opt := ConfigOption{}
+		for f, fv := range m {
+			var ok bool
+			switch f {
+			case "type":
+				opt.Type, ok = fv.(string)
+			case "default":
+				opt.Default, ok = fv, true
+			case "enum":
+				opt.Enum, ok = fv.([]interface{})
+			case "min":
+				opt.Min, ok = configBound(fv)
+			case "max":
+				opt.Max, ok = configBound(fv)
+			case "description":
+				opt.Description, ok = fv.(string)
+			default:
+				return nil, fmt.Errorf("option '%s' has an unknown field '%s'", k, f)
+			}
+			if !ok {
+				return nil, fmt.Errorf("option '%s' has an invalid %s '%v'", k, f, fv)
+			}
+		}
+
+		switch opt.Type {
+		case ConfigTypeNumber, ConfigTypeInteger, ConfigTypeString, ConfigTypeBoolean, ConfigTypeArray:
+		default:
+			return nil, fmt.Errorf("option '%s' has an unsupported type '%s'", k, opt.Type)
+		}
+		if opt.Default != nil {
+			if err := opt.validate(opt.Default); err != nil {
+				return nil, fmt.Errorf("option '%s' has an invalid default: %s", k, err)
+			}
+		}
+
+		schema[k] = opt
+	}
+
+	return schema, nil
+}
+
+func configNumber(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case float32:
+		return float64(t), true
+	case int:
+		return float64(t), true
+	case int64:
+		return float64(t), true
+	}
+
+	return 0, false
+}
+
+func configBound(v interface{}) (*float64, bool) {
+	n, ok := configNumber(v)
+	return &n, ok
+}
+
+func configEqual(a, b interface{}) bool {
+	if na, ok := configNumber(a); ok {
+		nb, ok := configNumber(b)
+		return ok && na == nb
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+func article(t string) string {
+	if t == ConfigTypeInteger {
+		return "an integer"
+	}
+
+	return "a " + t
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// closestKey returns the key closest to k if it is likely a typo of it
+func closestKey(k string, keys []string) string {
+	best, dist := "", 3
+	for _, c := range keys {
+		if d := editDistance(strings.ToLower(k), strings.ToLower(c)); d < dist {
+			best, dist = c, d
+		}
+	}
+
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
+
+func min(v ...int) int {
+	m := v[0]
+	for _, n := range v[1:] {
+		if n < m {
+			m = n
+		}
+	}
+
+	return m
+}
@@ -1,6 +1,8 @@
 package js
 
 import (
+	"context"
+
 	"github.com/concreteit/greenlight/internal"
 	"github.com/concreteit/greenlight/xml"
 )
@@ -9,6 +11,13 @@ type ContextOption = func(c *Context) error
 
 type ContextHandler = func(c *Context) []interface{}
 
+func WithContext(ctx context.Context) ContextOption {
+	return func(c *Context) error {
+		c.ctx = ctx
+		return nil
+	}
+}
+
 func WithMetaFields(fields map[string]interface{}) ContextOption {
 	return func(c *Context) error {
 		c.fields = fields
@@ -39,9 +48,7 @@ func WithNode(n xml.Node) ContextOption {
 
 func WithDocument(doc *xml.Document) ContextOption {
 	return func(c *Context) error {
-		c.Xsd = Xsd{
-			document: doc,
-		}
+		c.Xsd.document = doc
 		c.Document = doc
 		return nil
 	}
@@ -49,15 +56,18 @@ func WithDocument(doc *xml.Document) ContextOption {
 
 func WithCollection(coll *xml.Collection) ContextOption {
 	return func(c *Context) error {
+		c.Xsd.collection = coll
 		c.Collection = coll
 		return nil
 	}
 }
 
 type Context struct {
+	ctx     context.Context
 	script  *Script
 	emitter *internal.Emitter
 	fields  map[string]interface{}
+	omitted int
 
 	// export to js runtime
 	Config     internal.M
@@ -72,6 +82,7 @@ type Context struct {
 
 func NewContext(script *Script, opts ...ContextOption) (*Context, error) {
 	ctx := &Context{
+		ctx:    context.Background(),
 		script: script,
 	}
 	ctx.Worker = NewWorker(ctx)
@@ -84,6 +95,8 @@ func NewContext(script *Script, opts ...ContextOption) (*Context, error) {
 			}
 		}
 	}
+	ctx.Xsd.ctx = ctx.ctx
+	ctx.Xsd.omitted = &ctx.omitted
 
 	return ctx, nil
 }
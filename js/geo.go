@@ -0,0 +1,329 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/concreteit/greenlight/internal"
+	"github.com/concreteit/greenlight/xml"
+)
+
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371000.0
+
+var (
+	ErrGeoUnsupportedSrs = errors.New("unsupported spatial reference system")
+	ErrGeoInvalidPos     = errors.New("invalid position")
+
+	srsEpsgRe = regexp.MustCompile(`(?i)(?:EPSG|crs).*?([0-9]+)$`)
+)
+
+// Point is a WGS84 coordinate in degrees
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+type BBox struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains reports whether p is inside the bounding box
+func (b BBox) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// ellipsoid of a projected reference system
+type ellipsoid struct {
+	a float64 // semi-major axis
+	f float64 // flattening
+}
+
+var (
+	ellipsoidGRS80 = ellipsoid{a: 6378137.0, f: 1 / 298.257222101}
+	ellipsoidWGS84 = ellipsoid{a: 6378137.0, f: 1 / 298.257223563}
+)
+
+// transverseMercator is a projected reference system, the datums used
+// (ETRS89, SWEREF99 and WGS84) are treated as equal which is accurate to
+// within a meter
+type transverseMercator struct {
+	ellipsoid
+	lon0          float64 // central meridian in degrees
+	k0            float64 // scale factor
+	falseNorthing float64
+	falseEasting  float64
+	northingFirst bool // axis order of the reference system
+}
+
+func utm(e ellipsoid, zone int) transverseMercator {
+	return transverseMercator{
+		ellipsoid:    e,
+		lon0:         float64(zone*6 - 183),
+		k0:           0.9996,
+		falseEasting: 500000,
+	}
+}
+
+// projection returns the projection of the reference system with the given
+// EPSG code, ok is false for geographic reference systems
+func projection(code int) (transverseMercator, bool, error) {
+	switch {
+	case code == 4326 || code == 4258 || code == 4619:
+		// WGS84, ETRS89 and SWEREF99 geographic
+		return transverseMercator{}, false, nil
+	case code == 3006:
+		// SWEREF99 TM
+		tm := utm(ellipsoidGRS80, 33)
+		tm.northingFirst = true
+		return tm, true, nil
+	case code >= 25828 && code <= 25838:
+		// ETRS89 / UTM zone 28N-38N
+		return utm(ellipsoidGRS80, code-25800), true, nil
+	case code >= 32601 && code <= 32660:
+		// WGS84 / UTM zone 1N-60N
+		return utm(ellipsoidWGS84, code-32600), true, nil
+	}
+
+	return transverseMercator{}, false, fmt.Errorf("%w 'EPSG:%d'", ErrGeoUnsupportedSrs, code)
+}
+
+// toWGS84 converts projected coordinates to WGS84, using the Gauss-Krüger
+// formulas as published by Lantmäteriet
+func (tm transverseMercator) toWGS84(easting, northing float64) Point {
+	e2 := tm.f * (2 - tm.f)
+	n := tm.f / (2 - tm.f)
+	aRoof := tm.a / (1 + n) * (1 + n*n/4 + n*n*n*n/64)
+
+	d1 := n/2 - 2*n*n/3 + 37*n*n*n/96 - n*n*n*n/360
+	d2 := n*n/48 + n*n*n/15 - 437*n*n*n*n/1440
+	d3 := 17*n*n*n/480 - 37*n*n*n*n/840
+	d4 := 4397 * n * n * n * n / 161280
+
+	aStar := e2 + e2*e2 + e2*e2*e2 + e2*e2*e2*e2
+	bStar := -(7*e2*e2 + 17*e2*e2*e2 + 30*e2*e2*e2*e2) / 6
+	cStar := (224*e2*e2*e2 + 889*e2*e2*e2*e2) / 120
+	dStar := -(4279 * e2 * e2 * e2 * e2) / 1260
+
+	xi := (northing - tm.falseNorthing) / (tm.k0 * aRoof)
+	eta := (easting - tm.falseEasting) / (tm.k0 * aRoof)
+	xiPrim := xi -
+		d1*math.Sin(2*xi)*math.Cosh(2*eta) -
+		d2*math.Sin(4*xi)*math.Cosh(4*eta) -
+		d3*math.Sin(6*xi)*math.Cosh(6*eta) -
+		d4*math.Sin(8*xi)*math.Cosh(8*eta)
+	etaPrim := eta -
+		d1*math.Cos(2*xi)*math.Sinh(2*eta) -
+		d2*math.Cos(4*xi)*math.Sinh(4*eta) -
+		d3*math.Cos(6*xi)*math.Sinh(6*eta) -
+		d4*math.Cos(8*xi)*math.Sinh(8*eta)
+
+	phiStar := math.Asin(math.Sin(xiPrim) / math.Cosh(etaPrim))
+	deltaLambda := math.Atan(math.Sinh(etaPrim) / math.Cos(xiPrim))
+	sinPhi := math.Sin(phiStar)
+	lat := phiStar + sinPhi*math.Cos(phiStar)*(aStar+
+		bStar*math.Pow(sinPhi, 2)+
+		cStar*math.Pow(sinPhi, 4)+
+		dStar*math.Pow(sinPhi, 6))
+
+	return Point{
+		Lat: lat * 180 / math.Pi,
+		Lon: tm.lon0 + deltaLambda*180/math.Pi,
+	}
+}
+
+// parseSrs returns the EPSG code of a reference system name, e.g. "EPSG:3006",
+// "urn:ogc:def:crs:EPSG::3006", "http://www.opengis.net/def/crs/EPSG/0/3006",
+// "3006" or "WGS84". OGC CRS84 (e.g. "urn:ogc:def:crs:OGC:1.3:CRS84") is
+// WGS84 as well.
+func parseSrs(name string) (int, error) {
+	name = strings.TrimSpace(name)
+	upper := strings.ToUpper(strings.ReplaceAll(name, " ", ""))
+	switch upper {
+	case "WGS84", "WGS-84", "":
+		return 4326, nil
+	}
+	if strings.HasSuffix(upper, "CRS84") {
+		return 4326, nil
+	}
+
+	if code, err := strconv.Atoi(name); err == nil {
+		return code, nil
+	}
+	if m := srsEpsgRe.FindStringSubmatch(name); m != nil {
+		return strconv.Atoi(m[1])
+	}
+
+	return 0, fmt.Errorf("%w '%s'", ErrGeoUnsupportedSrs, name)
+}
+
+func toRad(deg float64) float64 { return deg * math.Pi / 180 }
+
+// geoDistance returns the great-circle distance between a and b in meters
+func geoDistance(a, b Point) float64 {
+	dLat := toRad(b.Lat - a.Lat)
+	dLon := toRad(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return earthRadius * 2 * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
+
+// geoBearing returns the initial bearing from a to b in degrees (0-360)
+func geoBearing(a, b Point) float64 {
+	dLon := toRad(b.Lon - a.Lon)
+	y := math.Sin(dLon) * math.Cos(toRad(b.Lat))
+	x := math.Cos(toRad(a.Lat))*math.Sin(toRad(b.Lat)) -
+		math.Sin(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Cos(dLon)
+
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+func geoBBox(points []Point) BBox {
+	if len(points) == 0 {
+		return BBox{}
+	}
+
+	b := BBox{
+		MinLat: points[0].Lat,
+		MinLon: points[0].Lon,
+		MaxLat: points[0].Lat,
+		MaxLon: points[0].Lon,
+	}
+	for _, p := range points[1:] {
+		b.MinLat = math.Min(b.MinLat, p.Lat)
+		b.MinLon = math.Min(b.MinLon, p.Lon)
+		b.MaxLat = math.Max(b.MaxLat, p.Lat)
+		b.MaxLon = math.Max(b.MaxLon, p.Lon)
+	}
+
+	return b
+}
+
+// geoContains reports whether p is inside polygon, using the even-odd rule
+func geoContains(polygon []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lon < (b.Lon-a.Lon)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lon {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
+
+// geoTransform converts x (easting) and y (northing) in the reference system
+// srs to WGS84, for geographic reference systems x is the longitude and y the
+// latitude
+func geoTransform(x, y float64, srs string) (Point, error) {
+	code, err := parseSrs(srs)
+	if err != nil {
+		return Point{}, err
+	}
+
+	tm, projected, err := projection(code)
+	if err != nil {
+		return Point{}, err
+	} else if !projected {
+		return Point{Lat: y, Lon: x}, nil
+	}
+
+	return tm.toWGS84(x, y), nil
+}
+
+// geoLocation returns the WGS84 coordinate of a NeTEx Location element, either
+// from its Longitude and Latitude or from its gml:pos. The reference system
+// of gml:pos is resolved from its srsName, the srsName of the Location or the
+// given default (e.g. DefaultLocationSystem), in that order. The coordinates
+// of gml:pos are read in the axis order of the reference system.
+func geoLocation(node xml.Node, srs string) (Point, error) {
+	lon, lonErr := nodeFloat(node, "Longitude")
+	lat, latErr := nodeFloat(node, "Latitude")
+	if lonErr == nil && latErr == nil {
+		return Point{Lat: lat, Lon: lon}, nil
+	}
+
+	pos, ok := node.First("gml:pos").Get().(xml.Node)
+	if !ok {
+		return Point{}, fmt.Errorf("%w, neither Longitude/Latitude nor gml:pos found", ErrGeoInvalidPos)
+	}
+	if v, ok := pos.Attr("srsName").Get().(string); ok && v != "" {
+		srs = v
+	} else if v, ok := node.Attr("srsName").Get().(string); ok && v != "" {
+		srs = v
+	}
+
+	fields := strings.Fields(pos.Text())
+	if len(fields) < 2 {
+		return Point{}, fmt.Errorf("%w '%s'", ErrGeoInvalidPos, pos.Text())
+	}
+	a, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("%w '%s'", ErrGeoInvalidPos, pos.Text())
+	}
+	b, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Point{}, fmt.Errorf("%w '%s'", ErrGeoInvalidPos, pos.Text())
+	}
+
+	code, err := parseSrs(srs)
+	if err != nil {
+		return Point{}, err
+	}
+	tm, projected, err := projection(code)
+	if err != nil {
+		return Point{}, err
+	}
+	switch {
+	case !projected:
+		// geographic reference systems are latitude first
+		return Point{Lat: a, Lon: b}, nil
+	case tm.northingFirst:
+		return tm.toWGS84(b, a), nil
+	}
+
+	return tm.toWGS84(a, b), nil
+}
+
+func nodeFloat(node xml.Node, q string) (float64, error) {
+	v, ok := node.TextAt(q).Get().(string)
+	if !ok {
+		return 0, xml.ErrNodeNotFound
+	}
+
+	return strconv.ParseFloat(strings.TrimSpace(v), 64)
+}
+
+var geoModule = internal.M{
+	"distance": geoDistance,
+	"bearing":  geoBearing,
+	"bbox":     geoBBox,
+	"contains": geoContains,
+	"transform": func(x, y float64, srs string) internal.Result {
+		return internal.NewResult(geoTransform(x, y, srs))
+	},
+	"location": func(node xml.Node, srs string) internal.Result {
+		return internal.NewResult(geoLocation(node, srs))
+	},
+	"isWGS84": func(srs string) bool {
+		code, err := parseSrs(srs)
+		return err == nil && code == 4326
+	},
+	"isSupported": func(srs string) bool {
+		code, err := parseSrs(srs)
+		if err != nil {
+			return false
+		}
+		_, _, err = projection(code)
+		return err == nil
+	},
+}
@@ -0,0 +1,108 @@
+package js
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/concreteit/greenlight/xml"
+)
+
+func TestParseSrs(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"", 4326},
+		{"WGS84", 4326},
+		{"EPSG:4326", 4326},
+		{"epsg:3006", 3006},
+		{"3006", 3006},
+		{"urn:ogc:def:crs:EPSG::3006", 3006},
+		{"urn:ogc:def:crs:EPSG:6.6:25832", 25832},
+		{"http://www.opengis.net/def/crs/EPSG/0/25833", 25833},
+		{"CRS84", 4326},
+		{"urn:ogc:def:crs:OGC:1.3:CRS84", 4326},
+		{"http://www.opengis.net/def/crs/OGC/1.3/CRS84", 4326},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSrs(tt.name)
+		if err != nil {
+			t.Errorf("parseSrs(%q): %v", tt.name, err)
+		} else if got != tt.want {
+			t.Errorf("parseSrs(%q) = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parseSrs("RT90"); !errors.Is(err, ErrGeoUnsupportedSrs) {
+		t.Errorf("parseSrs(%q): got %v, want %v", "RT90", err, ErrGeoUnsupportedSrs)
+	}
+}
+
+func TestGeoTransform(t *testing.T) {
+	tests := []struct {
+		srs  string
+		x, y float64
+		want Point
+	}{
+		{"EPSG:3006", 674571.87, 6580743.01, Point{Lat: 59.3293, Lon: 18.0686}},  // Stockholm
+		{"EPSG:3006", 719583.12, 7536069.97, Point{Lat: 67.8558, Lon: 20.2253}},  // Kiruna
+		{"EPSG:25832", 565834.36, 5934037.95, Point{Lat: 53.5511, Lon: 9.9937}},  // Hamburg
+		{"EPSG:25832", 597979.90, 6643118.99, Point{Lat: 59.9139, Lon: 10.7522}}, // Oslo
+		{"EPSG:25833", 391779.26, 5820072.16, Point{Lat: 52.5200, Lon: 13.4050}}, // Berlin
+		{"EPSG:4326", 18.0686, 59.3293, Point{Lat: 59.3293, Lon: 18.0686}},       // Stockholm
+		{"urn:ogc:def:crs:EPSG::3006", 674571.87, 6580743.01, Point{Lat: 59.3293, Lon: 18.0686}},
+	}
+
+	for _, tt := range tests {
+		got, err := geoTransform(tt.x, tt.y, tt.srs)
+		if err != nil {
+			t.Errorf("geoTransform(%v, %v, %q): %v", tt.x, tt.y, tt.srs, err)
+		} else if d := geoDistance(got, tt.want); d > 0.5 {
+			t.Errorf("geoTransform(%v, %v, %q) = %v, %.2fm off %v", tt.x, tt.y, tt.srs, got, d, tt.want)
+		}
+	}
+}
+
+func TestGeoLocation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.xml")
+	content := `<Locations xmlns:gml="http://www.opengis.net/gml/3.2">
+  <Location><Longitude>18.0686</Longitude><Latitude>59.3293</Latitude></Location>
+  <Location><gml:pos srsName="EPSG:3006">6580743.01 674571.87</gml:pos></Location>
+  <Location srsName="EPSG:25833"><gml:pos>391779.26 5820072.16</gml:pos></Location>
+  <Location><gml:pos>59.3293 18.0686</gml:pos></Location>
+  <Location><gml:pos>59.3293</gml:pos></Location>
+  <Location><gml:pos srsName="EPSG:2400">6580743 1628293</gml:pos></Location>
+  <Location/>
+</Locations>`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := xml.NewDocument("test.xml", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	nodes, ok := doc.Find("//Location").Get().([]xml.Node)
+	if !ok || len(nodes) != 7 {
+		t.Fatalf("expected 7 locations, got %v", doc.Find("//Location").Get())
+	}
+
+	stockholm, berlin := Point{Lat: 59.3293, Lon: 18.0686}, Point{Lat: 52.5200, Lon: 13.4050}
+	for i, want := range []Point{stockholm, stockholm, berlin, stockholm} {
+		got, err := geoLocation(nodes[i], "EPSG:4326")
+		if err != nil {
+			t.Errorf("location %d: %v", i, err)
+		} else if d := geoDistance(got, want); d > 0.5 {
+			t.Errorf("location %d: got %v, %.2fm off %v", i, got, d, want)
+		}
+	}
+	for i, want := range []error{ErrGeoInvalidPos, ErrGeoUnsupportedSrs, ErrGeoInvalidPos} {
+		if _, err := geoLocation(nodes[4+i], "EPSG:4326"); !errors.Is(err, want) {
+			t.Errorf("location %d: got %v, want %v", 4+i, err, want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package js
 import (
 	"errors"
 	"strings"
-	"time"
 
 	"github.com/concreteit/greenlight/internal"
 	"github.com/concreteit/greenlight/xml"
@@ -17,6 +16,12 @@ var (
 	ErrTypeXSD         = errors.New("xsd")
 )
 
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
 func join(values ...string) string {
 	return strings.Join(values, "/")
 }
@@ -27,15 +32,8 @@ var (
 	pathFrameDefaults = join(pathDataObjects, "CompositeFrame", "FrameDefaults")
 	pathFrames        = join(pathDataObjects, "CompositeFrame", "frames")
 	std               = internal.M{
-		"time": internal.M{
-			"validLocation": func(name string) internal.Result {
-				if _, err := time.LoadLocation(name); err != nil {
-					return internal.NewResult(false, err)
-				}
-
-				return internal.NewResult(true, nil)
-			},
-		},
+		"geo":  geoModule,
+		"time": timeModule,
 		"xpath": internal.M{
 			"join": join,
 			"path": internal.M{
@@ -65,24 +63,66 @@ var (
 			"TYPE_GENERAL":     ErrTypeGeneral.Error(),
 			"TYPE_NOT_FOUND":   ErrTypeNotFound.Error(),
 			"TYPE_QUALITY":     ErrTypeQuality.Error(),
+			"SEVERITY_ERROR":   SeverityError,
+			"SEVERITY_WARNING": SeverityWarning,
+			"SEVERITY_INFO":    SeverityInfo,
 			/* "XSD_VALIDATION_INVALID": ErrXSDValidationInvalid.Error(), */
 		},
 		"types": internal.M{},
 	}
 )
 
+// setNodeLocation adds the location of node to extra, keeping values already
+// set
+func setNodeLocation(extra internal.M, node xml.Node) {
+	set := func(k string, v interface{}) {
+		if _, ok := extra[k]; !ok && v != nil && v != 0 && v != "" {
+			extra[k] = v
+		}
+	}
+
+	set("line", node.Line())
+	set("column", node.Column())
+	set("path", node.Path())
+	if v, ok := node.Attr("id").Get().(string); ok {
+		set("id", v)
+	}
+	if v, ok := node.Attr("version").Get().(string); ok {
+		set("version", v)
+	}
+}
+
+// Require returns the std module with the given name, scripts are given a
+// require function resolving other modules as well (see ModuleLoader)
 func Require(name string) interface{} { return std[name] }
 
 type ScriptError struct {
-	Type    string     `json:"type"`
-	Message string     `json:"message"`
-	Extra   internal.M `json:"extra"`
+	Type     string     `json:"type"`
+	Severity string     `json:"severity,omitempty"`
+	Message  string     `json:"message"`
+	Extra    internal.M `json:"extra"`
 }
 
+// newScriptError creates a script error, the severity is read from
+// `extra.severity` and defaults to "error". If `extra.node` is set, the
+// location of the node is added to the extras not set explicitly (`line`,
+// `column`, `path`, `id` and `version`).
 func newScriptError(t, msg string, extra internal.M) ScriptError {
+	severity := SeverityError
+	if extra != nil {
+		if v, ok := extra["severity"].(string); ok && v != "" {
+			severity = v
+		}
+		if node, ok := extra["node"].(xml.Node); ok {
+			delete(extra, "node")
+			setNodeLocation(extra, node)
+		}
+	}
+
 	return ScriptError{
-		Type:    t,
-		Message: msg,
-		Extra:   extra,
+		Type:     t,
+		Severity: severity,
+		Message:  msg,
+		Extra:    extra,
 	}
 }
@@ -0,0 +1,89 @@
+package js
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+// ScriptExample is an example of a finding reported by a script
+type ScriptExample struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Description string `json:"description,omitempty"`
+}
+
+// ScriptInfo describes a script, e.g. to list the available rules
+type ScriptInfo struct {
+	Name          string          `json:"name"`
+	Description   string          `json:"description,omitempty"`
+	Version       string          `json:"version,omitempty"`
+	Author        string          `json:"author,omitempty"`
+	Tags          []string        `json:"tags"`
+	Scope         string          `json:"scope"`
+	NetexVersions []string        `json:"netexVersions"`
+	Documentation string          `json:"documentation,omitempty"`
+	Examples      []ScriptExample `json:"examples"`
+	ConfigSchema  ConfigSchema    `json:"configSchema"`
+}
+
+// exportMetadata reads the optional metadata declared by the script, e.g.
+//
+//	const version = "1.0.0";
+//	const tags = ["references"];
+//	const netexVersions = ["netex@1.2", "epip@1.1.2"];
+func exportMetadata(vm *goja.Runtime, s *Script) error {
+	s.tags = []string{}
+	s.netexVersions = []string{}
+	s.examples = []ScriptExample{}
+
+	fields := []struct {
+		name   string
+		target interface{}
+	}{
+		{"description", &s.description},
+		{"version", &s.version},
+		{"author", &s.author},
+		{"tags", &s.tags},
+		{"netexVersions", &s.netexVersions},
+		{"documentation", &s.documentation},
+	}
+	for _, f := range fields {
+		if vm.Get(f.name) == nil {
+			continue
+		}
+		if err := exportVariable(f.name, vm, f.target); err != nil {
+			return fmt.Errorf("script '%s' has an invalid value for '%s': %w", s.name, f.name, err)
+		}
+	}
+
+	if v := vm.Get("examples"); v != nil {
+		examples := []map[string]interface{}{}
+		if err := vm.ExportTo(v, &examples); err != nil {
+			return fmt.Errorf("script '%s' has invalid examples: %w", s.name, err)
+		}
+		for i, e := range examples {
+			ex := ScriptExample{}
+			for k, target := range map[string]*string{
+				"code":        &ex.Code,
+				"message":     &ex.Message,
+				"description": &ex.Description,
+			} {
+				if e[k] == nil {
+					continue
+				}
+				str, ok := e[k].(string)
+				if !ok {
+					return fmt.Errorf("script '%s' has an invalid %s in example %d", s.name, k, i)
+				}
+				*target = str
+			}
+			if ex.Message == "" {
+				return fmt.Errorf("script '%s' has an example %d without a message", s.name, i)
+			}
+			s.examples = append(s.examples, ex)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package js
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+var defaultModuleLoader = NewModuleLoader()
+
+// ModuleLoader resolves the modules required by scripts. Relative names (e.g.
+// "./lib/refs") are resolved from the directory of the requiring script or
+// module, other names from the std modules ("errors", "xpath", ...) followed by
+// the library paths of the loader. Modules are compiled once and shared by
+// every script using the loader, but evaluated once per runtime.
+type ModuleLoader struct {
+	paths   []string
+	mu      sync.Mutex
+	modules map[string]*module
+}
+
+type module struct {
+	path     string
+	checksum string
+	program  *goja.Program
+	// see statelessSource
+	stateless bool
+}
+
+// NewModuleLoader creates a loader resolving library modules from paths
+func NewModuleLoader(paths ...string) *ModuleLoader {
+	return &ModuleLoader{
+		paths:   paths,
+		modules: map[string]*module{},
+	}
+}
+
+// resolve returns the absolute file path of the module name required from dir
+func (l *ModuleLoader) resolve(dir, name string) (string, error) {
+	dirs := l.paths
+	if strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		dirs = []string{dir}
+	}
+
+	for _, d := range dirs {
+		base := filepath.Join(d, name)
+		for _, p := range []string{base, base + ".js", filepath.Join(base, "index.js")} {
+			if fi, err := os.Stat(p); err == nil && !fi.IsDir() {
+				return filepath.Abs(p)
+			}
+		}
+	}
+
+	return "", fmt.Errorf("module '%s' not found", name)
+}
+
+// load returns the compiled module at path, compiling it on first use
+func (l *ModuleLoader) load(path string) (*module, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if m, ok := l.modules[path]; ok {
+		return m, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// the wrapper is kept on the first line to preserve line numbers, the
+	// constants of a stateless module are passed to __constants once
+	// evaluated so that they can be frozen
+	constants, stateless := statelessSource(path, string(source))
+	program, err := goja.Compile(path, "(function(exports, require, module, __filename, __dirname, __constants) {"+string(source)+"\n__constants(["+strings.Join(constants, ", ")+"]);\n})", true)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &module{
+		path:      path,
+		checksum:  fmt.Sprintf("%x", sha256.Sum256(source)),
+		program:   program,
+		stateless: stateless,
+	}
+	l.modules[path] = m
+
+	return m, nil
+}
+
+// moduleRegistry holds the modules evaluated in a single runtime
+type moduleRegistry struct {
+	vm      *goja.Runtime
+	loader  *ModuleLoader
+	exports map[string]*goja.Object
+	loaded  []*module
+	// values of the constants of the evaluated modules
+	constants []goja.Value
+}
+
+func newModuleRegistry(vm *goja.Runtime, loader *ModuleLoader) *moduleRegistry {
+	return &moduleRegistry{
+		vm:        vm,
+		loader:    loader,
+		exports:   map[string]*goja.Object{},
+		loaded:    []*module{},
+		constants: []goja.Value{},
+	}
+}
+
+// require returns the require function of a script or module in dir
+func (r *moduleRegistry) require(dir string) func(name string) goja.Value {
+	return func(name string) goja.Value {
+		if v, ok := std[name]; ok {
+			return r.vm.ToValue(v)
+		}
+
+		v, err := r.evaluate(dir, name)
+		if err != nil {
+			panic(r.vm.NewGoError(err))
+		}
+
+		return v
+	}
+}
+
+// stateless reports whether every module evaluated in the runtime is
+// stateless, see statelessSource
+func (r *moduleRegistry) stateless() bool {
+	for _, m := range r.loaded {
+		if !m.stateless {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *moduleRegistry) evaluate(dir, name string) (goja.Value, error) {
+	path, err := r.loader.resolve(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	if obj, ok := r.exports[path]; ok {
+		return obj.Get("exports"), nil
+	}
+
+	m, err := r.loader.load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fn, err := r.vm.RunProgram(m.program)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := goja.AssertFunction(fn)
+	if !ok {
+		return nil, fmt.Errorf("module '%s' is not a function", name)
+	}
+
+	// registered before evaluation so that cyclic requires get the partial
+	// exports, as in CommonJS
+	obj := r.vm.NewObject()
+	exports := r.vm.NewObject()
+	obj.Set("exports", exports)
+	r.exports[path] = obj
+	r.loaded = append(r.loaded, m)
+
+	moduleDir := filepath.Dir(path)
+	constants := func(v goja.Value) { r.constants = append(r.constants, v) }
+	if _, err := call(goja.Undefined(), exports, r.vm.ToValue(r.require(moduleDir)), obj, r.vm.ToValue(path), r.vm.ToValue(moduleDir), r.vm.ToValue(constants)); err != nil {
+		delete(r.exports, path)
+		return nil, err
+	}
+
+	return obj.Get("exports"), nil
+}
@@ -0,0 +1,135 @@
+package js
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// requireValue compiles a script at dir/main.js requiring the module name, and
+// returns the exported value of the module as a string
+func requireValue(t *testing.T, loader *ModuleLoader, dir, name string) (string, error) {
+	t.Helper()
+
+	path := filepath.Join(dir, "main.js")
+	source := `const name = "main"; const value = JSON.stringify(require("` + name + `"));`
+	s, err := NewScript(path, []byte(source), WithModuleLoader(loader))
+	if err != nil {
+		return "", err
+	}
+
+	vm, err := s.Runtime()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return vm.Get("value").String(), nil
+}
+
+func TestModuleLoader(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib")
+	scripts := filepath.Join(dir, "scripts")
+	writeFiles(t, dir, map[string]string{
+		"scripts/helpers/a.js":     `module.exports = { a: require("../shared").shared };`,
+		"scripts/shared.js":        `exports.shared = "shared";`,
+		"scripts/dir/index.js":     `module.exports = "index";`,
+		"lib/refs.js":              `module.exports = { refs: require("./util").util, std: typeof require("errors").ConsistencyError };`,
+		"lib/util.js":              `exports.util = "util";`,
+		"lib/nested/index.js":      `module.exports = "nested";`,
+		"scripts/cycle/a.js":       `exports.early = "a"; const b = require("./b"); exports.b = b.seen;`,
+		"scripts/cycle/b.js":       `const a = require("./a"); exports.seen = a.early + (a.b === undefined ? "-partial" : "");`,
+		"scripts/throws.js":        `throw new Error("broken module");`,
+		"scripts/syntax.js":        `module.exports = {`,
+		"scripts/refs.js":          `module.exports = "relative";`,
+		"scripts/helpers/local.js": `module.exports = require("refs");`,
+	})
+
+	tests := []struct {
+		name string
+		want string
+		err  string
+	}{
+		{"./helpers/a", `{"a":"shared"}`, ""},
+		{"./helpers/a.js", `{"a":"shared"}`, ""},
+		{"./dir", `"index"`, ""},
+		{"refs", `{"refs":"util","std":"function"}`, ""},
+		{"nested", `"nested"`, ""},
+		{"./refs", `"relative"`, ""},
+		{"./helpers/local", `{"refs":"util","std":"function"}`, ""},
+		{"./cycle/a", `{"early":"a","b":"a-partial"}`, ""},
+		{"./missing", "", "module './missing' not found"},
+		{"missing", "", "module 'missing' not found"},
+		{"./throws", "", "broken module"},
+		{"./syntax", "", "SyntaxError"},
+	}
+
+	loader := NewModuleLoader(lib)
+	for _, tt := range tests {
+		got, err := requireValue(t, loader, scripts, tt.name)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("require(%q): got error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("require(%q): unexpected error %s", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("require(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestModuleLoaderCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib/refs.js": `module.exports = 1;`,
+	})
+
+	loader := NewModuleLoader(filepath.Join(dir, "lib"))
+	source := []byte(`const name = "main"; const refs = require("refs");`)
+	a, err := NewScript(filepath.Join(dir, "a.js"), source, WithModuleLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a loader compiles a module once, changes are only picked up by a new one
+	writeFiles(t, dir, map[string]string{
+		"lib/refs.js": `module.exports = 2;`,
+	})
+	b, err := NewScript(filepath.Join(dir, "a.js"), source, WithModuleLoader(loader))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loader.modules) != 1 {
+		t.Errorf("got %d compiled modules, want 1", len(loader.modules))
+	}
+	if a.Checksum() != b.Checksum() {
+		t.Error("expected scripts sharing a compiled module to have the same checksum")
+	}
+
+	c, err := NewScript(filepath.Join(dir, "a.js"), source, WithModuleLoader(NewModuleLoader(filepath.Join(dir, "lib"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Checksum() == c.Checksum() {
+		t.Error("expected the checksum of a script to cover the modules it requires")
+	}
+}
@@ -0,0 +1,325 @@
+package js
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+)
+
+// runtimePool holds initialized runtimes of a script, borrowed by every run
+// of the script and its worker tasks instead of creating and initializing a
+// new runtime each time.
+//
+// A runtime is only reused if no state can carry over from one use to the
+// next: the script and the modules it requires declare nothing but functions
+// and constants at the top level (see statelessSource), and once initialized
+// every object reachable from the global object, builtins and prototypes
+// included, is frozen (see lockdownSource). Any other script gets a new
+// runtime for every use.
+type runtimePool struct {
+	script *Script
+	max    int
+
+	mu   sync.Mutex
+	idle []*pooledRuntime
+}
+
+type pooledRuntime struct {
+	vm      *goja.Runtime
+	modules *moduleRegistry
+	// number of modules evaluated once initialized, a module required while
+	// running is evaluated in the runtime and not frozen
+	loaded   int
+	reusable bool
+}
+
+// lockdownSource freezes every object reachable from the global object and
+// the given roots (the constants of the script and its modules), false if any
+// of them holds state freezing doesn't protect (e.g. a Map). Objects backed by
+// Go values can't be frozen and are skipped, their state is shared by every
+// runtime whether pooled or not.
+const lockdownSource = `(function (roots, moduleRoots) {
+  // methods only accepting instances of the types holding state of their own
+  const brands = [
+    Map.prototype.has, Set.prototype.has, WeakMap.prototype.has, WeakSet.prototype.has,
+    Date.prototype.getTime, Reflect.getOwnPropertyDescriptor(ArrayBuffer.prototype, "byteLength").get,
+  ];
+  const holdsState = (o) => ArrayBuffer.isView(o) || brands.some((fn) => {
+    try {
+      fn.call(o, undefined);
+      return true;
+    } catch (e) {
+      return false;
+    }
+  });
+  const seen = new WeakSet();
+  const stack = [globalThis, ...roots, ...moduleRoots];
+  let ok = true;
+  while (stack.length > 0) {
+    const o = stack.pop();
+    if (o === null || (typeof o !== "object" && typeof o !== "function") || seen.has(o)) {
+      continue;
+    }
+    seen.add(o);
+    if (holdsState(o)) {
+      ok = false;
+    }
+    for (const k of Reflect.ownKeys(o)) {
+      const d = Reflect.getOwnPropertyDescriptor(o, k);
+      if (d) {
+        stack.push(d.value, d.get, d.set);
+      }
+    }
+    stack.push(Reflect.getPrototypeOf(o));
+    try {
+      Object.freeze(o);
+    } catch (e) {}
+  }
+  return ok;
+})`
+
+var lockdownProgram = goja.MustCompile("lockdown", lockdownSource, true)
+
+func newRuntimePool(script *Script) *runtimePool {
+	return &runtimePool{
+		script: script,
+		max:    2 * runtime.GOMAXPROCS(0),
+		idle:   []*pooledRuntime{},
+	}
+}
+
+// newPooledRuntime wraps an initialized runtime of the script, locking it
+// down if the script is stateless
+func (p *runtimePool) newPooledRuntime(vm *goja.Runtime, modules *moduleRegistry) *pooledRuntime {
+	rt := &pooledRuntime{
+		vm:      vm,
+		modules: modules,
+		loaded:  len(modules.loaded),
+	}
+	if !p.script.stateless || !modules.stateless() {
+		return rt
+	}
+
+	roots, err := vm.RunString("[" + strings.Join(p.script.constants, ", ") + "]")
+	if err != nil {
+		return rt
+	}
+	v, err := vm.RunProgram(lockdownProgram)
+	if err != nil {
+		return rt
+	}
+	lockdown, ok := goja.AssertFunction(v)
+	if !ok {
+		return rt
+	}
+	if v, err := lockdown(goja.Undefined(), roots, vm.ToValue(modules.constants)); err == nil {
+		rt.reusable = v.ToBoolean()
+	}
+
+	return rt
+}
+
+// get borrows an idle runtime, or initializes a new one if there is none
+func (p *runtimePool) get() (*pooledRuntime, error) {
+	p.mu.Lock()
+	if n := len(p.idle); n > 0 {
+		rt := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return rt, nil
+	}
+	p.mu.Unlock()
+
+	vm, modules, err := p.script.runtime()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.newPooledRuntime(vm, modules), nil
+}
+
+// put returns a borrowed runtime to the pool, it is discarded unless it can be
+// reused. A runtime which may have been interrupted or left in an unknown
+// state must be discarded instead.
+func (p *runtimePool) put(rt *pooledRuntime) {
+	if !rt.reusable || len(rt.modules.loaded) != rt.loaded {
+		return
+	}
+	rt.vm.ClearInterrupt()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.idle) < p.max {
+		p.idle = append(p.idle, rt)
+	}
+}
+
+// statelessSource reports whether the top level of a script or module only
+// declares functions and constants, module exports and directives (e.g. "use
+// strict"), so that no binding of it can change once initialized. The values
+// of the constants are frozen by lockdownSource, which can't reach state held
+// in closures, so the initializers are limited to expressions which can't
+// create any (see constants.expr). It returns the names of the constants.
+func statelessSource(name, source string) ([]string, bool) {
+	program, err := parser.ParseFile(nil, name, source, 0)
+	if err != nil {
+		return nil, false
+	}
+
+	c := &constants{names: []string{}, std: map[string]bool{}}
+	for _, stmt := range program.Body {
+		switch s := stmt.(type) {
+		case *ast.FunctionDeclaration, *ast.EmptyStatement:
+		case *ast.LexicalDeclaration:
+			if s.Token != token.CONST {
+				return nil, false
+			}
+			for _, b := range s.List {
+				id, ok := b.Target.(*ast.Identifier)
+				if !ok || !c.expr(b.Initializer) {
+					return nil, false
+				}
+				c.names = append(c.names, string(id.Name))
+				if module, ok := requiredName(b.Initializer); ok && std[module] != nil {
+					c.std[string(id.Name)] = true
+				}
+			}
+		case *ast.ExpressionStatement:
+			switch e := s.Expression.(type) {
+			case *ast.StringLiteral:
+			case *ast.AssignExpression:
+				if e.Operator != token.ASSIGN || !isExportTarget(e.Left) || !c.expr(e.Right) {
+					return nil, false
+				}
+			default:
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return c.names, true
+}
+
+// constants are the top-level constants of a script or module
+type constants struct {
+	names []string
+	// constants holding a std module, e.g. xpath
+	std map[string]bool
+}
+
+// expr reports whether e is a constant initializer: literals, functions,
+// references to other values and operators on them, requires and calls to
+// std modules. Calls to functions written in js could return closures holding
+// state, and new objects (e.g. a Map) could hold state of their own.
+func (c *constants) expr(e ast.Expression) bool {
+	switch v := e.(type) {
+	case nil, *ast.StringLiteral, *ast.NumberLiteral, *ast.BooleanLiteral, *ast.NullLiteral, *ast.RegExpLiteral,
+		*ast.Identifier, *ast.FunctionLiteral, *ast.ArrowFunctionLiteral:
+		return true
+	case *ast.TemplateLiteral:
+		return v.Tag == nil && c.exprs(v.Expressions)
+	case *ast.ArrayLiteral:
+		return c.exprs(v.Value)
+	case *ast.ObjectLiteral:
+		for _, p := range v.Value {
+			switch p := p.(type) {
+			case *ast.PropertyShort:
+				if p.Initializer != nil {
+					return false
+				}
+			case *ast.PropertyKeyed:
+				if !c.expr(p.Key) || !c.expr(p.Value) {
+					return false
+				}
+			case *ast.SpreadElement:
+				if !c.expr(p.Expression) {
+					return false
+				}
+			default:
+				return false
+			}
+		}
+		return true
+	case *ast.SpreadElement:
+		return c.expr(v.Expression)
+	case *ast.DotExpression:
+		return c.expr(v.Left)
+	case *ast.BracketExpression:
+		return c.expr(v.Left) && c.expr(v.Member)
+	case *ast.BinaryExpression:
+		return c.expr(v.Left) && c.expr(v.Right)
+	case *ast.UnaryExpression:
+		return v.Operator != token.INCREMENT && v.Operator != token.DECREMENT && c.expr(v.Operand)
+	case *ast.ConditionalExpression:
+		return c.expr(v.Test) && c.expr(v.Consequent) && c.expr(v.Alternate)
+	case *ast.CallExpression:
+		if _, ok := requiredName(v); ok {
+			return true
+		}
+		return c.stdMember(v.Callee) && c.exprs(v.ArgumentList)
+	}
+
+	return false
+}
+
+func (c *constants) exprs(list []ast.Expression) bool {
+	for _, e := range list {
+		if !c.expr(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// stdMember reports whether e is a member of a std module, e.g. xpath.join
+func (c *constants) stdMember(e ast.Expression) bool {
+	dot, ok := e.(*ast.DotExpression)
+	if !ok {
+		return false
+	}
+	if id, ok := dot.Left.(*ast.Identifier); ok {
+		return c.std[string(id.Name)]
+	}
+
+	return c.stdMember(dot.Left)
+}
+
+// requiredName returns the name of the module of a require("name") call
+func requiredName(e ast.Expression) (string, bool) {
+	call, ok := e.(*ast.CallExpression)
+	if !ok || len(call.ArgumentList) != 1 {
+		return "", false
+	}
+	if id, ok := call.Callee.(*ast.Identifier); !ok || id.Name != "require" {
+		return "", false
+	}
+	lit, ok := call.ArgumentList[0].(*ast.StringLiteral)
+	if !ok {
+		return "", false
+	}
+
+	return string(lit.Value), true
+}
+
+// isExportTarget reports whether e is module.exports, exports.name or
+// module.exports.name
+func isExportTarget(e ast.Expression) bool {
+	dot, ok := e.(*ast.DotExpression)
+	if !ok {
+		return false
+	}
+	if id, ok := dot.Left.(*ast.Identifier); ok {
+		return id.Name == "exports" || id.Name == "module" && dot.Identifier.Name == "exports"
+	}
+
+	return isExportTarget(dot.Left)
+}
@@ -0,0 +1,204 @@
+package js
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/concreteit/greenlight/internal"
+	"github.com/concreteit/greenlight/xml"
+)
+
+func TestStatelessSource(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`"use strict"; const a = 1; function main(ctx) { let b = a; return []; }`, true},
+		{`const lib = require("./lib"); exports.f = function () {}; module.exports.g = 1;`, true},
+		{`module.exports = { f() {} };`, true},
+		{`const xpath = require("xpath"); const p = xpath.join(xpath.path.FRAMES, "Line"); const q = [{ p, a: -1 }];`, true},
+		{`let count = 0; function main(ctx) { count++; return []; }`, false},
+		{`const lib = require("./lib"); const next = lib.counter();`, false},
+		{`const next = (() => { let n = 0; return () => n++; })();`, false},
+		{`const seen = new Map();`, false},
+		{`var seen = {};`, false},
+		{`class Cache {}`, false},
+		{`Array.prototype.last = function () {};`, false},
+		{`other.exports = {};`, false},
+		{`exports.count += 1;`, false},
+	}
+
+	for _, tt := range tests {
+		if _, got := statelessSource("test.js", tt.source); got != tt.want {
+			t.Errorf("statelessSource(%q) = %v, want %v", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestRuntimePoolIsolation(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		reusable bool
+	}{
+		{"stateless", `const name = "main"; const seen = []; function main(ctx) { return []; }`, true},
+		{"top-level let", `const name = "main"; let count = 0; function main(ctx) { return []; }`, false},
+		{"map", `const name = "main"; const seen = new Map(); function main(ctx) { return []; }`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewScript(filepath.Join(t.TempDir(), "main.js"), []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rt, err := s.pool.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.pool.put(rt)
+			next, err := s.pool.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reused := next == rt; reused != tt.reusable {
+				t.Errorf("expected runtime reused to be %v", tt.reusable)
+			}
+		})
+	}
+}
+
+func TestRuntimePoolFrozen(t *testing.T) {
+	source := `const name = "main"; const seen = []; function main(ctx) { return []; }`
+	s, err := NewScript(filepath.Join(t.TempDir(), "main.js"), []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := s.pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{
+		`seen.push(1)`,
+		`Array.prototype.last = function () {}`,
+		`Object.prototype.polluted = true`,
+		`globalThis.added = true`,
+	} {
+		if _, err := rt.vm.RunString(`"use strict"; ` + code); err == nil {
+			t.Errorf("expected %s to throw in a pooled runtime", code)
+		}
+	}
+}
+
+func TestRuntimePoolFrozenModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib.js": `const cache = []; exports.add = (v) => cache.push(v);`,
+	})
+	source := `const name = "main"; const lib = require("./lib"); function main(ctx) { return []; }`
+	s, err := NewScript(filepath.Join(dir, "main.js"), []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := s.pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rt.reusable {
+		t.Fatal("expected the runtime to be reusable")
+	}
+	if _, err := rt.vm.RunString(`lib.add(1)`); err == nil {
+		t.Error("expected a constant of a module to be frozen in a pooled runtime")
+	}
+}
+
+func TestRuntimePoolLazyRequire(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib.js": `exports.f = function () {};`,
+	})
+	source := `const name = "main"; function main(ctx) { require("./lib"); return []; }`
+	s, err := NewScript(filepath.Join(dir, "main.js"), []byte(source))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := s.pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.vm.RunString(`main()`); err != nil {
+		t.Fatal(err)
+	}
+	s.pool.put(rt)
+
+	// the module required while running is not frozen, so the runtime is
+	// discarded
+	if next, err := s.pool.get(); err != nil {
+		t.Fatal(err)
+	} else if next == rt {
+		t.Error("expected a runtime requiring a module while running to be discarded")
+	}
+}
+
+// BenchmarkScriptRun runs passingTimesIsNotDecreasing, which runs a worker
+// task per ServiceJourney, on every testdata line file with pooled runtimes
+// and with a new runtime for every use
+func BenchmarkScriptRun(b *testing.B) {
+	path := "../builtin/passingTimesIsNotDecreasing.js"
+	source, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	files, err := filepath.Glob("../testdata/line_*.xml")
+	if err != nil || len(files) == 0 {
+		b.Fatal("no testdata line files")
+	}
+
+	docs := []*xml.Document{}
+	for _, f := range files {
+		doc, err := xml.NewDocument(filepath.Base(f), f)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer doc.Close()
+		docs = append(docs, doc)
+	}
+
+	emitter := internal.NewEmitter("bench")
+	go emitter.Start()
+	defer emitter.Close()
+
+	for _, pooled := range []bool{true, false} {
+		name := "fresh"
+		if pooled {
+			name = "pooled"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			s, err := NewScript(path, source)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !pooled {
+				s.stateless = false
+				s.pool = newRuntimePool(s)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for _, doc := range docs {
+					res := s.Run(context.Background(), doc.Name, doc, emitter, xml.NewCollection(), nil)
+					if err := res.Message(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package js
 
 import (
+	"context"
 	"crypto/sha256"
 	"fmt"
+	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -15,35 +17,128 @@ type ScriptResult struct {
 	Name        string
 	Description string
 	Errors      []ScriptError
+	// number of errors found but not returned by the script, e.g. when
+	// exceeding the number of errors stored by libxml
+	Omitted int
 }
 
+const (
+	// ScopeDocument scripts are run once for every document in a validation
+	ScopeDocument = "document"
+	// ScopeCollection scripts are run once for every validation, against the
+	// collection of all documents
+	ScopeCollection = "collection"
+)
+
 type Script struct {
-	name        string
-	description string
-	source      []byte
-	checksum    string
-	filePath    string
-	program     *goja.Program
+	name          string
+	description   string
+	version       string
+	author        string
+	tags          []string
+	netexVersions []string
+	documentation string
+	examples      []ScriptExample
+	scope         string
+	config        ConfigSchema
+	source        []byte
+	checksum      string
+	filePath      string
+	program       *goja.Program
+	loader        *ModuleLoader
+	pool          *runtimePool
+	// whether the top level only declares functions and constants, see
+	// statelessSource
+	stateless bool
+	constants []string
+}
+
+type ScriptOption func(s *Script)
+
+// WithModuleLoader sets the loader resolving the modules required by the
+// script, by default only relative modules are resolved
+func WithModuleLoader(loader *ModuleLoader) ScriptOption {
+	return func(s *Script) {
+		s.loader = loader
+	}
 }
 
 func (s *Script) Name() string { return s.name }
 
 func (s *Script) Description() string { return s.description }
 
+func (s *Script) Version() string { return s.version }
+
+func (s *Script) Author() string { return s.author }
+
+// Tags returns the tags grouping the script with related rules, e.g.
+// "references" or "timetable"
+func (s *Script) Tags() []string { return s.tags }
+
+// NetexVersions returns the NeTEx versions or profiles the script applies to
+// (e.g. "netex@1.2", "epip@1.1.2"), empty if not declared
+func (s *Script) NetexVersions() []string { return s.netexVersions }
+
+// Documentation returns the long description of the script
+func (s *Script) Documentation() string { return s.documentation }
+
+// Examples returns examples of the findings reported by the script
+func (s *Script) Examples() []ScriptExample { return s.examples }
+
+func (s *Script) Scope() string { return s.scope }
+
+func (s *Script) Checksum() string { return s.checksum }
+
+// ConfigSchema returns the options accepted in the config of the script, nil
+// if the script declares no schema
+func (s *Script) ConfigSchema() ConfigSchema { return s.config }
+
+// Info returns the metadata of the script
+func (s *Script) Info() ScriptInfo {
+	config := s.config
+	if config == nil {
+		config = ConfigSchema{}
+	}
+
+	return ScriptInfo{
+		Name:          s.name,
+		Description:   s.description,
+		Version:       s.version,
+		Author:        s.author,
+		Tags:          s.tags,
+		Scope:         s.scope,
+		NetexVersions: s.netexVersions,
+		Documentation: s.documentation,
+		Examples:      s.examples,
+		ConfigSchema:  config,
+	}
+}
+
+// Runtime returns a new runtime with the script initialized, runs of the
+// script borrow runtimes from a pool instead
 func (s *Script) Runtime() (*goja.Runtime, error) {
+	vm, _, err := s.runtime()
+	return vm, err
+}
+
+func (s *Script) runtime() (*goja.Runtime, *moduleRegistry, error) {
 	vm := goja.New()
+	modules := newModuleRegistry(vm, s.loader)
 
-	if err := vm.GlobalObject().Set("require", Require); err != nil {
-		return nil, err
+	if err := vm.GlobalObject().Set("require", modules.require(filepath.Dir(s.filePath))); err != nil {
+		return nil, nil, err
 	}
 
 	vm.SetFieldNameMapper(fieldNameMapper{})
-	vm.RunProgram(s.program)
+	if _, err := vm.RunProgram(s.program); err != nil {
+		return nil, nil, err
+	}
 
-	return vm, nil
+	return vm, modules, nil
 }
 
 func (s *Script) Run(
+	c context.Context,
 	name string,
 	doc *xml.Document,
 	emitter *internal.Emitter,
@@ -56,34 +151,82 @@ func (s *Script) Run(
 		"document": name,
 		"valid":    false,
 	}
+
+	return s.run(
+		c,
+		emitter,
+		fields,
+		config,
+		WithNode(doc),
+		WithDocument(doc),
+		WithCollection(coll),
+	)
+}
+
+// RunCollection runs the script once against the collection of all documents,
+// the context of the script has no document or node.
+func (s *Script) RunCollection(
+	c context.Context,
+	emitter *internal.Emitter,
+	coll *xml.Collection,
+	config map[string]interface{},
+) internal.Result {
+	fields := map[string]interface{}{
+		"scope":  "main",
+		"script": s.name,
+		"valid":  false,
+	}
+
+	return s.run(c, emitter, fields, config, WithCollection(coll))
+}
+
+func (s *Script) run(
+	c context.Context,
+	emitter *internal.Emitter,
+	fields map[string]interface{},
+	config map[string]interface{},
+	opts ...ContextOption,
+) internal.Result {
 	emitter.Emit(internal.EventTypeScriptStart, fields)
 
 	var handler ContextHandler
 
-	vm, err := s.Runtime()
+	rt, err := s.pool.get()
 	if err != nil {
 		return internal.NewResult(nil, err)
 	}
+	vm := rt.vm
+	stop := interruptOnDone(c, vm)
+	defer func() {
+		stop()
+		if c.Err() == nil && err == nil {
+			s.pool.put(rt)
+		}
+	}()
 
-	if err := vm.ExportTo(vm.Get("main"), &handler); err != nil {
+	if err = vm.ExportTo(vm.Get("main"), &handler); err != nil {
 		return internal.NewResult(nil, err)
 	}
 
-	ctx, err := NewContext(
-		s,
-		WithConfig(config),
+	ctx, err := NewContext(s, append([]ContextOption{
+		WithContext(c),
+		WithConfig(s.config.Apply(config)),
 		WithEmitter(emitter),
 		WithMetaFields(fields),
-		WithNode(doc),
-		WithDocument(doc),
-		WithCollection(coll),
-	)
+	}, opts...)...)
 	if err != nil {
 		return internal.NewResult(nil, err)
 	}
 
+	values, err := callHandler(handler, ctx)
+	if cerr := c.Err(); cerr != nil {
+		return internal.NewResult(nil, cerr)
+	} else if err != nil {
+		return internal.NewResult(nil, err)
+	}
+
 	errors := []ScriptError{}
-	for _, r := range handler(ctx) {
+	for _, r := range values {
 		if v, ok := r.(ScriptError); !ok {
 			return internal.NewResult(nil, fmt.Errorf("expected '%v' is not of type ScriptError", r))
 		} else {
@@ -99,14 +242,59 @@ func (s *Script) Run(
 		Name:        s.name,
 		Description: s.description,
 		Errors:      errors,
+		Omitted:     ctx.omitted,
 	}, nil)
 }
 
-func NewScript(name string, source []byte) (*Script, error) {
+// interruptOnDone interrupts vm once c is done. The returned function must be
+// called when the vm is no longer running, once it returns vm is no longer
+// interrupted unless c is done.
+func interruptOnDone(c context.Context, vm *goja.Runtime) func() {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-c.Done():
+			vm.Interrupt(c.Err())
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// callHandler invokes handler, recovering exceptions and interrupts raised by
+// the js runtime into an error
+func callHandler(handler ContextHandler, ctx *Context) (res []interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	return handler(ctx), nil
+}
+
+// NewScript compiles the script at the file path name, relative modules are
+// required from the directory of name. The checksum of the script covers the
+// modules it requires when it is loaded, but not modules required later on
+// (e.g. inside a function).
+func NewScript(name string, source []byte, opts ...ScriptOption) (*Script, error) {
 	script := &Script{
 		source:   source,
-		checksum: fmt.Sprintf("%x", sha256.Sum256(source)),
 		filePath: name,
+		loader:   defaultModuleLoader,
+	}
+	for _, opt := range opts {
+		opt(script)
 	}
 
 	program, err := goja.Compile(name, string(source), true)
@@ -115,15 +303,45 @@ func NewScript(name string, source []byte) (*Script, error) {
 	}
 
 	script.program = program
+	script.constants, script.stateless = statelessSource(name, string(source))
+	script.pool = newRuntimePool(script)
 
-	vm := goja.New()
-	vm.RunProgram(program)
+	vm, modules, err := script.runtime()
+	if err != nil {
+		return nil, err
+	}
+	defer script.pool.put(script.pool.newPooledRuntime(vm, modules))
+
+	h := sha256.New()
+	h.Write(source)
+	for _, m := range modules.loaded {
+		fmt.Fprintf(h, "\x00%s\x00%s", m.path, m.checksum)
+	}
+	script.checksum = fmt.Sprintf("%x", h.Sum(nil))
 
 	if err := exportVariable("name", vm, &script.name); err != nil {
 		return nil, err
 	}
 
-	exportVariable("description", vm, &script.description)
+	if err := exportMetadata(vm, script); err != nil {
+		return nil, err
+	}
+
+	script.scope = ScopeDocument
+	exportVariable("scope", vm, &script.scope)
+	if script.scope != ScopeDocument && script.scope != ScopeCollection {
+		return nil, fmt.Errorf("script '%s' has an invalid scope '%s'", script.name, script.scope)
+	}
+
+	if v := vm.Get("configSchema"); v != nil {
+		schema := map[string]interface{}{}
+		if err := vm.ExportTo(v, &schema); err != nil {
+			return nil, fmt.Errorf("script '%s' has an invalid config schema: %w", script.name, err)
+		}
+		if script.config, err = parseConfigSchema(schema); err != nil {
+			return nil, fmt.Errorf("script '%s' has an invalid config schema: %w", script.name, err)
+		}
+	}
 
 	return script, nil
 }
@@ -0,0 +1,319 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/concreteit/greenlight/internal"
+)
+
+const secondsPerDay = 24 * 60 * 60
+
+var (
+	ErrTimeInvalid         = errors.New("invalid time")
+	ErrTimeInvalidDuration = errors.New("invalid duration")
+	ErrTimeInvalidDate     = errors.New("invalid date")
+	ErrTimeInvalidOffset   = errors.New("invalid day offset")
+
+	xsdTimeRe     = regexp.MustCompile(`^([0-9]{2}):([0-9]{2}):([0-9]{2}(?:\.[0-9]+)?)(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	xsdDateRe     = regexp.MustCompile(`^(-?[0-9]{4,})-([0-9]{2})-([0-9]{2})(Z|[+-][0-9]{2}:[0-9]{2})?$`)
+	xsdDurationRe = regexp.MustCompile(`^(-)?P(?:([0-9]+)Y)?(?:([0-9]+)M)?(?:([0-9]+)D)?(?:T(?:([0-9]+)H)?(?:([0-9]+)M)?(?:([0-9]+(?:\.[0-9]+)?)S)?)?$`)
+)
+
+// parseZoneOffset returns the offset in seconds of an xsd time zone ("Z",
+// "+01:00"), ok is false if v holds no time zone
+func parseZoneOffset(v string) (int, bool) {
+	if v == "" {
+		return 0, false
+	} else if v == "Z" {
+		return 0, true
+	}
+
+	h, _ := strconv.Atoi(v[1:3])
+	m, _ := strconv.Atoi(v[4:6])
+	offset := h*3600 + m*60
+	if v[0] == '-' {
+		offset = -offset
+	}
+
+	return offset, true
+}
+
+// parseXsdClock returns the number of seconds since midnight of an xsd:time
+// as written (e.g. "13:45:00"), and the offset in seconds of its time zone if
+// it has one
+func parseXsdClock(v string) (secs float64, offset int, zoned bool, err error) {
+	m := xsdTimeRe.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return 0, 0, false, fmt.Errorf("%w '%s'", ErrTimeInvalid, v)
+	}
+
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	s, _ := strconv.ParseFloat(m[3], 64)
+	if h > 24 || min > 59 || s >= 60 || (h == 24 && (min != 0 || s != 0)) {
+		return 0, 0, false, fmt.Errorf("%w '%s'", ErrTimeInvalid, v)
+	}
+
+	offset, zoned = parseZoneOffset(m[4])
+	return float64(h*3600+min*60) + s, offset, zoned, nil
+}
+
+// parseXsdTime returns the number of seconds since midnight of an xsd:time
+// (e.g. "13:45:00") on the clock it is written in, a time zone is not applied
+// (see passingTime and absoluteTime)
+func parseXsdTime(v string) (float64, error) {
+	secs, _, _, err := parseXsdClock(v)
+	return secs, err
+}
+
+// parseXsdDuration returns the number of seconds of an xsd:duration (e.g.
+// "PT1H30M"). Years and months have no fixed number of seconds and are
+// rejected.
+func parseXsdDuration(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	m := xsdDurationRe.FindStringSubmatch(v)
+	if m == nil || v == "P" || v == "-P" || strings.HasSuffix(v, "T") {
+		return 0, fmt.Errorf("%w '%s'", ErrTimeInvalidDuration, v)
+	}
+	if m[2] != "" && m[2] != "0" || m[3] != "" && m[3] != "0" {
+		return 0, fmt.Errorf("%w '%s', years and months have no fixed length", ErrTimeInvalidDuration, v)
+	}
+
+	secs := 0.0
+	for i, unit := range []float64{secondsPerDay, 3600, 60, 1} {
+		if f, err := strconv.ParseFloat(m[4+i], 64); err == nil {
+			secs += f * unit
+		}
+	}
+	if m[1] == "-" {
+		secs = -secs
+	}
+
+	return secs, nil
+}
+
+// parseXsdDateTime parses an xsd:dateTime (e.g. "2023-03-26T02:30:00"), a
+// value without time zone is in the location zone (UTC if empty)
+func parseXsdDateTime(v string, zone string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	date, clock, ok := strings.Cut(v, "T")
+	if !ok {
+		return time.Time{}, fmt.Errorf("%w '%s'", ErrTimeInvalidDate, v)
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// the time zone of a dateTime follows the time
+	tm := xsdTimeRe.FindStringSubmatch(clock)
+	if tm == nil {
+		return time.Time{}, fmt.Errorf("%w '%s'", ErrTimeInvalidDate, v)
+	}
+	if offset, ok := parseZoneOffset(tm[4]); ok {
+		loc = time.FixedZone("", offset)
+	}
+
+	t, err := parseXsdDate(date, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w '%s'", ErrTimeInvalidDate, v)
+	}
+	secs, err := parseXsdTime(tm[1] + ":" + tm[2] + ":" + tm[3])
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	whole, frac := math.Modf(secs)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, int(whole), int(frac*1e9), loc), nil
+}
+
+// parseXsdDate returns midnight of an xsd:date in loc, unless the date has a
+// time zone of its own
+func parseXsdDate(v string, loc *time.Location) (time.Time, error) {
+	m := xsdDateRe.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return time.Time{}, fmt.Errorf("%w '%s'", ErrTimeInvalidDate, v)
+	}
+	if offset, ok := parseZoneOffset(m[4]); ok {
+		loc = time.FixedZone("", offset)
+	}
+
+	y, _ := strconv.Atoi(m[1])
+	mon, _ := strconv.Atoi(m[2])
+	d, _ := strconv.Atoi(m[3])
+	t := time.Date(y, time.Month(mon), d, 0, 0, 0, 0, loc)
+	if t.Year() != y || int(t.Month()) != mon || t.Day() != d {
+		return time.Time{}, fmt.Errorf("%w '%s'", ErrTimeInvalidDate, v)
+	}
+
+	return t, nil
+}
+
+// parseDayOffset parses a DayOffset, given as a number or as the text of the
+// element. An empty value is no offset.
+func parseDayOffset(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case nil:
+		return 0, nil
+	case int64:
+		return int(t), nil
+	case int:
+		return t, nil
+	case float64:
+		if t == math.Trunc(t) {
+			return int(t), nil
+		}
+	case string:
+		if strings.TrimSpace(t) == "" {
+			return 0, nil
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(t)); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w '%v'", ErrTimeInvalidOffset, v)
+}
+
+// passingTimeDate is the operating day passing times are placed on when no
+// date is known, a day without daylight saving time changes in every zone
+const passingTimeDate = "2001-01-10"
+
+// passingTime returns the number of seconds from midnight of the operating
+// day of a time (e.g. ArrivalTime) and its day offset (e.g. ArrivalDayOffset),
+// ignoring daylight saving time changes. A time with a time zone of its own is
+// converted to zone (e.g. TimeZone of FrameDefaults), and taken as written if
+// zone is empty.
+func passingTime(v string, dayOffset interface{}, zone string) (float64, error) {
+	if zone != "" {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return 0, err
+		}
+		day, err := parseXsdDate(passingTimeDate, loc)
+		if err != nil {
+			return 0, err
+		}
+		secs, err := absoluteTime(passingTimeDate, v, dayOffset, zone)
+		if err != nil {
+			return 0, err
+		}
+
+		return secs - float64(day.Unix()), nil
+	}
+
+	secs, err := parseXsdTime(v)
+	if err != nil {
+		return 0, err
+	}
+	days, err := parseDayOffset(dayOffset)
+	if err != nil {
+		return 0, err
+	}
+
+	return secs + float64(days*secondsPerDay), nil
+}
+
+// absoluteTime returns the Unix time of a time and day offset on the
+// operating day date, in the time zone (e.g. TimeZone of FrameDefaults) unless
+// the time has a time zone of its own. Day offsets are calendar days, so
+// daylight saving time changes are respected.
+func absoluteTime(date, v string, dayOffset interface{}, zone string) (float64, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return 0, err
+	}
+	day, err := parseXsdDate(date, loc)
+	if err != nil {
+		return 0, err
+	}
+	days, err := parseDayOffset(dayOffset)
+	if err != nil {
+		return 0, err
+	}
+	secs, offset, zoned, err := parseXsdClock(v)
+	if err != nil {
+		return 0, err
+	}
+	if zoned {
+		loc = time.FixedZone("", offset)
+	}
+
+	h := int(secs) / 3600
+	min := int(secs) % 3600 / 60
+	whole, frac := math.Modf(secs - float64(h*3600+min*60))
+	t := time.Date(day.Year(), day.Month(), day.Day()+days, h, min, int(whole), int(frac*1e9), loc)
+
+	return float64(t.UnixNano()) / 1e9, nil
+}
+
+// zoneOffset returns the offset from UTC in seconds of the time zone at the
+// given Unix time
+func zoneOffset(zone string, unix float64) (int, error) {
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return 0, err
+	}
+
+	_, offset := time.Unix(int64(unix), 0).In(loc).Zone()
+	return offset, nil
+}
+
+// formatPassingTime formats a number of seconds from midnight as "HH:MM:SS",
+// followed by the day offset ("+1") if not on the operating day
+func formatPassingTime(secs float64) string {
+	s := int(math.Floor(secs))
+	days := int(math.Floor(float64(s) / secondsPerDay))
+	s -= days * secondsPerDay
+
+	res := fmt.Sprintf("%02d:%02d:%02d", s/3600, s%3600/60, s%60)
+	if days != 0 {
+		res += fmt.Sprintf("%+d", days)
+	}
+
+	return res
+}
+
+var timeModule = internal.M{
+	"validLocation": func(name string) internal.Result {
+		if _, err := time.LoadLocation(name); err != nil {
+			return internal.NewResult(false, err)
+		}
+
+		return internal.NewResult(true, nil)
+	},
+	"parseTime": func(v string) internal.Result {
+		return internal.NewResult(parseXsdTime(v))
+	},
+	"parseDuration": func(v string) internal.Result {
+		return internal.NewResult(parseXsdDuration(v))
+	},
+	"parseDateTime": func(v string, zone string) internal.Result {
+		t, err := parseXsdDateTime(v, zone)
+		if err != nil {
+			return internal.NewResult(nil, err)
+		}
+
+		return internal.NewResult(float64(t.UnixNano())/1e9, nil)
+	},
+	"parseDayOffset": func(v interface{}) internal.Result {
+		return internal.NewResult(parseDayOffset(v))
+	},
+	"passingTime": func(v string, dayOffset interface{}, zone string) internal.Result {
+		return internal.NewResult(passingTime(v, dayOffset, zone))
+	},
+	"absolute": func(date, v string, dayOffset interface{}, zone string) internal.Result {
+		return internal.NewResult(absoluteTime(date, v, dayOffset, zone))
+	},
+	"offset": func(zone string, unix float64) internal.Result {
+		return internal.NewResult(zoneOffset(zone, unix))
+	},
+	"formatTime": formatPassingTime,
+}
@@ -0,0 +1,78 @@
+package js
+
+import (
+	"testing"
+)
+
+func TestParseXsdTime(t *testing.T) {
+	tests := []struct {
+		v    string
+		want float64
+	}{
+		{"13:45:00", 13*3600 + 45*60},
+		{"00:30:00+02:00", 30 * 60},
+		{"23:30:00-05:00", 23*3600 + 30*60},
+		{"24:00:00", secondsPerDay},
+	}
+
+	for _, tt := range tests {
+		got, err := parseXsdTime(tt.v)
+		if err != nil {
+			t.Errorf("parseXsdTime(%q): %v", tt.v, err)
+		} else if got != tt.want {
+			t.Errorf("parseXsdTime(%q) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestPassingTime(t *testing.T) {
+	tests := []struct {
+		v         string
+		dayOffset interface{}
+		zone      string
+		want      float64
+	}{
+		{"23:30:00", "1", "", secondsPerDay + 23*3600 + 30*60},
+		{"00:30:00+02:00", nil, "", 30 * 60},
+		{"23:30:00", nil, "Europe/Stockholm", 23*3600 + 30*60},
+		{"00:30:00+01:00", nil, "Europe/Stockholm", 30 * 60},
+		{"23:30:00Z", nil, "Europe/Stockholm", secondsPerDay + 30*60},
+		{"00:30:00Z", "1", "America/New_York", 19*3600 + 30*60},
+	}
+
+	for _, tt := range tests {
+		got, err := passingTime(tt.v, tt.dayOffset, tt.zone)
+		if err != nil {
+			t.Errorf("passingTime(%q, %v, %q): %v", tt.v, tt.dayOffset, tt.zone, err)
+		} else if got != tt.want {
+			t.Errorf("passingTime(%q, %v, %q) = %v, want %v", tt.v, tt.dayOffset, tt.zone, got, tt.want)
+		}
+	}
+}
+
+func TestAbsoluteTime(t *testing.T) {
+	tests := []struct {
+		date      string
+		v         string
+		dayOffset interface{}
+		zone      string
+		want      float64
+	}{
+		// 2023-03-26 00:00 CET
+		{"2023-03-26", "00:00:00", nil, "Europe/Stockholm", 1679785200},
+		// clocks go forward at 02:00, so 03:30 is 2.5 hours after midnight
+		{"2023-03-26", "03:30:00", nil, "Europe/Stockholm", 1679785200 + 2*3600 + 30*60},
+		{"2023-03-25", "03:30:00", 1, "Europe/Stockholm", 1679785200 + 2*3600 + 30*60},
+		// a time zone of the time itself wins over the zone
+		{"2023-03-26", "00:00:00Z", nil, "Europe/Stockholm", 1679788800},
+	}
+
+	for _, tt := range tests {
+		got, err := absoluteTime(tt.date, tt.v, tt.dayOffset, tt.zone)
+		if err != nil {
+			t.Errorf("absoluteTime(%q, %q, %v, %q): %v", tt.date, tt.v, tt.dayOffset, tt.zone, err)
+		} else if got != tt.want {
+			t.Errorf("absoluteTime(%q, %q, %v, %q) = %v, want %v", tt.date, tt.v, tt.dayOffset, tt.zone, got, tt.want)
+		}
+	}
+}
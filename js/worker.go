@@ -1,6 +1,7 @@
 package js
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/concreteit/greenlight/internal"
@@ -35,20 +36,29 @@ func (w *Worker) Queue(handler string, node xml.Node, params map[string]any) {
 }
 
 func (w *Worker) Run() internal.Result {
-	queue := internal.NewQueue()
+	queue := internal.NewQueue[[]interface{}]()
 	for _, t := range w.tasks {
 		t := t
-		queue.Add(func() internal.Task {
-			return func(id int) internal.Result {
+		queue.Add(func() internal.Task[[]interface{}] {
+			return func(tctx context.Context, id int) (res []interface{}, err error) {
 				var handler ContextHandler
 
-				vm, err := w.ctx.script.Runtime()
+				pool := w.ctx.script.pool
+				rt, err := pool.get()
 				if err != nil {
-					return internal.NewResult(nil, err)
+					return nil, err
 				}
+				vm := rt.vm
+				stop := interruptOnDone(tctx, vm)
+				defer func() {
+					stop()
+					if tctx.Err() == nil && err == nil {
+						pool.put(rt)
+					}
+				}()
 
 				if err := vm.ExportTo(vm.Get(t.Handler), &handler); err != nil {
-					return internal.NewResult(nil, err)
+					return nil, err
 				}
 
 				fields := map[string]interface{}{
@@ -61,6 +71,7 @@ func (w *Worker) Run() internal.Result {
 				}
 
 				ctx := &Context{
+					ctx:     tctx,
 					emitter: w.ctx.emitter,
 					fields:  fields,
 					script:  w.ctx.script,
@@ -74,28 +85,24 @@ func (w *Worker) Run() internal.Result {
 				}
 				ctx.Worker = NewWorker(ctx)
 
-				return internal.NewResult(handler(ctx), nil)
+				return callHandler(handler, ctx)
 			}
 		}())
 	}
 
 	res := []ScriptError{}
-	for _, r := range queue.Run() {
-		if r.IsErr() {
-			return r
+	for i, r := range queue.Run(w.ctx.ctx) {
+		if r.Err != nil {
+			return internal.NewResult(nil, r.Err)
 		}
-		if r.Get() == nil {
-			return internal.NewResult(nil, fmt.Errorf("unexpected result (nil) returned from task '%v'", r))
+		if r.Value == nil {
+			return internal.NewResult(nil, fmt.Errorf("unexpected result (nil) returned from task %d", i+1))
 		}
-		if v, ok := r.Get().([]interface{}); !ok {
-			return internal.NewResult(nil, fmt.Errorf("expected '%v' to be of type []interface{}", r))
-		} else {
-			for _, vs := range v {
-				if vse, ok := vs.(ScriptError); !ok {
-					return internal.NewResult(nil, fmt.Errorf("expected '%v' to be of type ScriptError", vs))
-				} else {
-					res = append(res, vse)
-				}
+		for _, vs := range r.Value {
+			if vse, ok := vs.(ScriptError); !ok {
+				return internal.NewResult(nil, fmt.Errorf("expected '%v' to be of type ScriptError", vs))
+			} else {
+				res = append(res, vse)
 			}
 		}
 	}
@@ -1,8 +1,10 @@
 package js
 
 import (
+	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"sync"
 
 	"github.com/concreteit/greenlight/internal"
@@ -10,9 +12,16 @@ import (
 )
 
 var (
-	xpathEleRe = regexp.MustCompile("^(?i)[a-z]")
-	xsdCache   = &XsdCache{
-		data: map[string]*xml.Schema{},
+	xpathEleRe     = regexp.MustCompile("^(?i)[a-z]")
+	xsdElementRe   = regexp.MustCompile(`^Element '(?:\{[^}]*\})?([^']+)'(?:, attribute '([^']+)')?:`)
+	xsdExpectedRe  = regexp.MustCompile(`Expected is(?: one of)? \( ([^)]+) \)`)
+	xsdActualRe    = regexp.MustCompile(`(?:The value '([^']*)'|: '([^']*)' is not a valid value)`)
+	xsdNamespaceRe = regexp.MustCompile(`\{[^}]*\}`)
+	xsdCache       = &XsdCache{
+		data: map[string]*xsdCacheEntry{},
+	}
+	constraintCache = &ConstraintCache{
+		data: map[string]*xml.ConstraintSet{},
 	}
 	internalXSDPaths = map[string]string{
 		"epip@1.1.2":    "xsd/epip/1.1.2/NeTEx_publication_reduced.xsd",
@@ -25,23 +34,125 @@ var (
 var (
 	ErrXSDSchemaNotFound    = fmt.Errorf("xsd schema not found")
 	ErrXSDValidationInvalid = fmt.Errorf("invalid document")
+	ErrXSDNoDocument        = fmt.Errorf("no document to validate in context")
 )
 
+const defaultXSDVersion = "netex@1.2"
+
+// XsdCache holds the schemas parsed per path. A parsed schema is only read
+// while validating, so it is shared by concurrent validations.
 type XsdCache struct {
-	sync.RWMutex
-	data map[string]*xml.Schema
+	sync.Mutex
+	data map[string]*xsdCacheEntry
+}
+
+type xsdCacheEntry struct {
+	sync.Mutex
+	schema *xml.Schema
+}
+
+func (c *XsdCache) entry(k string) *xsdCacheEntry {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.data[k]
+	if !ok {
+		e = &xsdCacheEntry{}
+		c.data[k] = e
+	}
+
+	return e
 }
 
 func (c *XsdCache) Get(k string) *xml.Schema {
-	return c.data[k]
+	e := c.entry(k)
+	e.Lock()
+	defer e.Unlock()
+
+	return e.schema
 }
 
 func (c *XsdCache) Set(k string, v *xml.Schema) {
-	c.data[k] = v
+	e := c.entry(k)
+	e.Lock()
+	defer e.Unlock()
+
+	e.schema = v
+}
+
+// Load returns the schema at xsdPath, parsing it on first use. Only callers
+// loading the same schema wait for it to be parsed, the cache itself is never
+// locked while libxml is running.
+func (c *XsdCache) Load(xsdPath string) (*xml.Schema, error) {
+	e := c.entry(xsdPath)
+	e.Lock()
+	defer e.Unlock()
+
+	if e.schema == nil {
+		schema, err := xml.NewSchema(xsdPath)
+		if err != nil {
+			return nil, err
+		}
+		e.schema = schema
+	}
+
+	return e.schema, nil
+}
+
+// ConstraintCache holds the identity constraints compiled per schema
+type ConstraintCache struct {
+	sync.Mutex
+	data map[string]*xml.ConstraintSet
+}
+
+// Get returns the constraints of the schema at xsdPath, compiling them on
+// first use
+func (c *ConstraintCache) Get(xsdPath string) (*xml.ConstraintSet, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if s, ok := c.data[xsdPath]; ok {
+		return s, nil
+	}
+
+	s, err := xml.CompileConstraints(xsdPath)
+	if err != nil {
+		return nil, err
+	}
+	c.data[xsdPath] = s
+
+	return s, nil
 }
 
 type Xsd struct {
-	document *xml.Document
+	ctx        context.Context
+	document   *xml.Document
+	collection *xml.Collection
+	omitted    *int
+}
+
+// xsdErrorParams extracts the element, attribute, expected and actual values
+// from a libxml validation message
+func xsdErrorParams(msg string) internal.M {
+	params := internal.M{}
+	if m := xsdElementRe.FindStringSubmatch(msg); m != nil {
+		params["type"] = m[1]
+		if m[2] != "" {
+			params["attribute"] = m[2]
+		}
+	}
+	if m := xsdExpectedRe.FindStringSubmatch(msg); m != nil {
+		expected := []string{}
+		for _, v := range strings.Split(m[1], ",") {
+			expected = append(expected, strings.TrimSpace(xsdNamespaceRe.ReplaceAllString(v, "")))
+		}
+		params["expected"] = expected
+	}
+	if m := xsdActualRe.FindStringSubmatch(msg); m != nil {
+		params["actual"] = m[1] + m[2]
+	}
+
+	return params
 }
 
 func (x Xsd) Parse(version string) internal.Result {
@@ -49,23 +160,75 @@ func (x Xsd) Parse(version string) internal.Result {
 }
 
 func (x Xsd) Validate(v string) internal.Result {
+	if x.document == nil {
+		return internal.NewResult(nil, ErrXSDNoDocument)
+	}
+
 	scriptErrors := []ScriptError{}
-	if res, err := ValidateSchema(x.document, resolveXSDPath(v)); err != nil {
+	if res, err := ValidateSchema(x.ctx, x.document, ResolveXSDPath(v)); err != nil {
 		return internal.NewResult(nil, err)
 	} else if !res.Valid {
+		if n := res.Total - len(res.Errors); n > 0 && x.omitted != nil {
+			*x.omitted += n
+		}
 		for _, verr := range res.Errors {
+			severity := SeverityError
+			if verr.Level == xml.ErrorLevelWarning {
+				severity = SeverityWarning
+			}
+			extra := internal.M{
+				"line":   verr.Line,
+				"code":   fmt.Sprintf("XSD-%d", verr.Code),
+				"params": xsdErrorParams(verr.Message),
+			}
+			if verr.Column > 0 {
+				extra["column"] = verr.Column
+			}
+			if node, err := x.document.ElementAt(verr.Line); err == nil {
+				setNodeLocation(extra, node)
+			}
 			scriptErrors = append(scriptErrors, ScriptError{
-				Type:    ErrTypeXSD.Error(),
-				Message: verr.Message,
-				Extra: internal.M{
-					"line": verr.Line,
-				},
+				Type:     ErrTypeXSD.Error(),
+				Severity: severity,
+				Message:  verr.Message,
+				Extra:    extra,
 			})
 		}
 	}
 	return internal.NewResult(scriptErrors, nil)
 }
 
+// Constraints checks the identity constraints (key, keyref and unique) of the
+// schema version ("netex@1.2" if empty) against the document, or the whole
+// collection in a collection script. Only constraints of the given kinds are
+// checked, all if none are given.
+func (x Xsd) Constraints(version string, kinds ...string) internal.Result {
+	if version == "" {
+		version = defaultXSDVersion
+	}
+
+	var nodes []xml.Node
+	if x.document != nil {
+		nodes = []xml.Node{x.document}
+	} else if x.collection != nil {
+		nodes = x.collection.Nodes()
+	} else {
+		return internal.NewResult(nil, ErrXSDNoDocument)
+	}
+
+	set, err := constraintCache.Get(ResolveXSDPath(version))
+	if err != nil {
+		return internal.NewResult(nil, err)
+	}
+
+	constraintKinds := make([]xml.ConstraintKind, len(kinds))
+	for i, k := range kinds {
+		constraintKinds[i] = xml.ConstraintKind(k)
+	}
+
+	return internal.NewResult(set.Check(nodes, constraintKinds...))
+}
+
 type ValidationError struct {
 	err     error
 	details []error
@@ -82,7 +245,9 @@ func NewValidationError(err error, details []error) *ValidationError {
 	}
 }
 
-func resolveXSDPath(v string) string {
+// ResolveXSDPath resolves the path of a builtin schema version (e.g.
+// "netex@1.2"), any other value is treated as a path
+func ResolveXSDPath(v string) string {
 	if xsdPath := internalXSDPaths[v]; xsdPath != "" {
 		return xsdPath
 	} else {
@@ -90,17 +255,61 @@ func resolveXSDPath(v string) string {
 	}
 }
 
-func ValidateSchema(doc *xml.Document, xsdPath string) (*xml.ValidationResult, error) {
-	xsdCache.Lock()
-	defer xsdCache.Unlock()
-	var err error
-	schema := xsdCache.Get(xsdPath)
-	if schema == nil {
-		schema, err = xml.NewSchema(xsdPath)
-		if err != nil {
-			return nil, err
-		}
-		xsdCache.Set(xsdPath, schema)
+// ValidateSchema validates doc against the schema at xsdPath. Parsing the
+// schema and validating the document is done by libxml, which can't be
+// stopped once started: if ctx is done before it is complete the context error
+// is returned right away, while libxml runs to completion in the background
+// (the result is discarded, a parsed schema is cached) and keeps using its CPU
+// and memory until then. An abandoned run takes a slot of the scheduler of ctx
+// until it is complete, so that abandoned runs can't pile up beyond the number
+// of workers while new tasks keep being started. No lock is held while
+// validating, so other validations are never blocked by abandoned work.
+func ValidateSchema(ctx context.Context, doc *xml.Document, xsdPath string) (*xml.ValidationResult, error) {
+	type result struct {
+		res *xml.ValidationResult
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		res, err := validateSchema(ctx, doc, xsdPath)
+		done <- result{res, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		scheduler := internal.SchedulerFrom(ctx)
+		go func() {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			release := scheduler.Acquire()
+			defer release()
+			<-done
+		}()
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.res, r.err
+	}
+}
+
+func validateSchema(ctx context.Context, doc *xml.Document, xsdPath string) (*xml.ValidationResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	return schema.Validate(doc.FilePath)
+
+	schema, err := xsdCache.Load(xsdPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// every validation uses a validation context of its own (see
+	// validateStream in lxml.c), the schema is shared read-only. Errors beyond
+	// the findings stored by the rule are only counted.
+	return schema.Validate(doc.FilePath, internal.MaxErrorsFrom(ctx))
 }
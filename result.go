@@ -1,16 +1,81 @@
 package greenlight
 
 import (
+	"encoding/xml"
 	"fmt"
+	"reflect"
+	"sort"
+	"strings"
 	"time"
+
+	"github.com/concreteit/greenlight/js"
+)
+
+// DefaultMaxErrors is the number of findings stored per rule and document
+// unless configured otherwise, findings beyond it are only counted
+const DefaultMaxErrors = 1000
+
+type Severity string
+
+const (
+	SeverityError   Severity = js.SeverityError
+	SeverityWarning Severity = js.SeverityWarning
+	SeverityInfo    Severity = js.SeverityInfo
 )
 
-const maxErrorCount = 1000
+func ParseSeverity(v string) (Severity, error) {
+	switch s := Severity(v); s {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unknown severity '%s' (expected one of \"error\", \"warning\", \"info\")", v)
+}
+
+// ErrorCodeTimeout is the code of the finding added when a rule exceeds its
+// time budget
+const ErrorCodeTimeout = "GL-TIMEOUT"
+
+// defaultErrorCode returns the code of findings reported without one, derived
+// from the type of the finding (e.g. "GL-CONSISTENCY")
+func defaultErrorCode(t string) string {
+	if t == "" {
+		t = "general"
+	}
+
+	return "GL-" + strings.ToUpper(t)
+}
+
+type RuleStatus string
+
+const (
+	RuleStatusComplete  RuleStatus = "complete"
+	RuleStatusCancelled RuleStatus = "cancelled"
+	RuleStatusTimeout   RuleStatus = "timeout"
+	RuleStatusSkipped   RuleStatus = "skipped"
+)
 
 type ValidationResult struct {
 	Name            string            `json:"name" xml:"name,attr"`
 	Valid           bool              `json:"valid" xml:"valid,attr"`
+	Cancelled       bool              `json:"cancelled,omitempty" xml:"cancelled,attr,omitempty"`
+	SuppressedCount int               `json:"suppressed_count,omitempty" xml:"suppressedCount,attr,omitempty"`
 	ValidationRules []*RuleValidation `json:"validations,omitempty" xml:"Validation,omitempty"`
+
+	// wall-clock time spent validating the document, see Summary
+	Duration time.Duration `json:"-" xml:"-"`
+}
+
+// revalidate recomputes the validity of the result from its rules
+func (r *ValidationResult) revalidate() {
+	r.Valid = true
+	r.SuppressedCount = 0
+	for _, rv := range r.ValidationRules {
+		if !rv.Valid {
+			r.Valid = false
+		}
+		r.SuppressedCount += rv.Suppressed
+	}
 }
 
 func (r ValidationResult) CsvRecords(includeHeader bool) [][]string {
@@ -23,6 +88,12 @@ func (r ValidationResult) CsvRecords(includeHeader bool) [][]string {
 		"valid",
 		"error_line_no",
 		"error_message",
+		"error_severity",
+		"error_column_no",
+		"error_path",
+		"error_id",
+		"error_context",
+		"error_code",
 	}
 
 	if includeHeader {
@@ -30,13 +101,19 @@ func (r ValidationResult) CsvRecords(includeHeader bool) [][]string {
 	}
 
 	for _, v := range r.ValidationRules {
-		if v.Valid {
+		if len(v.Errors) == 0 {
 			res = append(res, []string{
 				r.Name,
 				v.Name,
 				v.Start.Format(time.RFC3339),
 				v.Stop.Format(time.RFC3339),
-				"true",
+				fmt.Sprintf("%t", v.Valid),
+				"",
+				"",
+				"",
+				"",
+				"",
+				"",
 				"",
 				"",
 			})
@@ -47,9 +124,15 @@ func (r ValidationResult) CsvRecords(includeHeader bool) [][]string {
 					v.Name,
 					v.Start.Format(time.RFC3339),
 					v.Stop.Format(time.RFC3339),
-					"false",
+					fmt.Sprintf("%t", v.Valid),
 					fmt.Sprintf("%d", err.Line),
 					err.Message,
+					string(err.Severity),
+					fmt.Sprintf("%d", err.Column),
+					err.Path,
+					err.ID,
+					formatSourceContext(err.Context),
+					err.Code,
 				})
 			}
 		}
@@ -59,9 +142,72 @@ func (r ValidationResult) CsvRecords(includeHeader bool) [][]string {
 }
 
 type TaskError struct {
-	Message string `json:"message"`
-	Line    int    `json:"line,omitempty"`
-	Type    string `json:"type,omitempty"`
+	Code     string   `json:"code,omitempty"`
+	Message  string   `json:"message"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Path     string   `json:"path,omitempty"`
+	ID       string   `json:"id,omitempty"`
+	Version  string   `json:"version,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Severity Severity `json:"severity,omitempty"`
+	Document string   `json:"document,omitempty"`
+
+	// structured details of the finding, e.g. type, id, expected and actual
+	Params Params `json:"params,omitempty" xml:"Params,omitempty"`
+
+	// lines of the document around the finding, only added on request
+	Context []SourceLine `json:"context,omitempty"`
+}
+
+// Params are the structured details of a finding. In XML every param is
+// rendered as a <param name="..."> element, list values as one <value> element
+// per item.
+type Params map[string]interface{}
+
+func (p Params) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		param := xml.StartElement{
+			Name: xml.Name{Local: "param"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: k}},
+		}
+
+		v := reflect.ValueOf(p[k])
+		if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+			if err := e.EncodeToken(param); err != nil {
+				return err
+			}
+			for i := 0; i < v.Len(); i++ {
+				if err := e.EncodeElement(fmt.Sprint(v.Index(i).Interface()), xml.StartElement{Name: xml.Name{Local: "value"}}); err != nil {
+					return err
+				}
+			}
+			if err := e.EncodeToken(param.End()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text := ""
+		if p[k] != nil {
+			text = fmt.Sprint(p[k])
+		}
+		if err := e.EncodeElement(text, param); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
 }
 
 type RuleValidation struct {
@@ -71,16 +217,136 @@ type RuleValidation struct {
 	Name        string        `json:"name" xml:"name,attr"`
 	Description string        `json:"description,omitempty" xml:"description,attr,omitempty"`
 	Valid       bool          `json:"valid" xml:"valid,attr"`
+	Status      RuleStatus    `json:"status,omitempty" xml:"status,attr,omitempty"`
+	Reason      string        `json:"reason,omitempty" xml:"reason,attr,omitempty"`
+	Cached      bool          `json:"cached,omitempty" xml:"cached,attr,omitempty"`
 	ErrorCount  int           `json:"error_count,omitempty" xml:"errorCount,attr,omitempty"`
+	Truncated   bool          `json:"truncated,omitempty" xml:"truncated,attr,omitempty"`
+	Suppressed  int           `json:"suppressed_count,omitempty" xml:"suppressedCount,attr,omitempty"`
 	Errors      []TaskError   `json:"errors,omitempty" xml:"Errors,omitempty"`
+
+	// findings suppressed by comments inside the document
+	SuppressedErrors []TaskError `json:"suppressed,omitempty" xml:"Suppressed,omitempty"`
+
+	// totals of a collection rule across every document, only set on the part
+	// of a collection rule validation belonging to a single document. If the
+	// collection rule was truncated the findings left out can't be attributed
+	// to a document, so ErrorCount of the document may be too low.
+	CollectionErrorCount int  `json:"collection_error_count,omitempty" xml:"collectionErrorCount,attr,omitempty"`
+	CollectionTruncated  bool `json:"collection_truncated,omitempty" xml:"collectionTruncated,attr,omitempty"`
+
+	maxErrors int
+	shared    bool // part of a collection rule validation shared by all documents
 }
 
+// AddError adds a finding to the rule, only findings with severity "error" (or
+// no severity at all) makes the rule invalid
 func (v *RuleValidation) AddError(err TaskError) {
-	v.Valid = false
+	if err.Severity == "" {
+		err.Severity = SeverityError
+	}
+	if err.Severity == SeverityError {
+		v.Valid = false
+	}
 
-	if v.ErrorCount < maxErrorCount {
-		v.ErrorCount++
+	v.ErrorCount++
+	if len(v.Errors) < v.errorLimit() {
 		v.Errors = append(v.Errors, err)
+	} else {
+		v.Truncated = true
+	}
+}
+
+// addOmitted counts findings that were found but not stored
+func (v *RuleValidation) addOmitted(n int) {
+	if n > 0 {
+		v.ErrorCount += n
+		v.Truncated = true
+	}
+}
+
+func (v *RuleValidation) errorLimit() int {
+	if v.maxErrors > 0 {
+		return v.maxErrors
+	}
+
+	return DefaultMaxErrors
+}
+
+// suppress removes every finding matching fn, returning the removed findings.
+// The validity of the rule is recomputed from the remaining findings.
+func (v *RuleValidation) suppress(fn func(err TaskError) bool) []TaskError {
+	errors := []TaskError{}
+	removed := []TaskError{}
+	valid := v.Status == RuleStatusComplete || v.Status == RuleStatusSkipped || v.Status == ""
+	for _, err := range v.Errors {
+		if fn(err) {
+			removed = append(removed, err)
+			continue
+		}
+		if err.Severity == SeverityError {
+			valid = false
+		}
+		errors = append(errors, err)
+	}
+
+	v.Errors = errors
+	v.ErrorCount -= len(removed)
+	v.Suppressed += len(removed)
+	v.Valid = valid
+
+	return removed
+}
+
+// forDocument returns the part of a collection rule validation that belongs to
+// the given document. Errors not tagged with a document belongs to every
+// document. The error count and truncation of the result only cover the
+// findings of the document, the totals of the collection are kept apart.
+func (v *RuleValidation) forDocument(name string) *RuleValidation {
+	rv := &RuleValidation{
+		Start:                v.Start,
+		Stop:                 v.Stop,
+		Duration:             v.Duration,
+		Name:                 v.Name,
+		Description:          v.Description,
+		Valid:                true,
+		Status:               v.Status,
+		Reason:               v.Reason,
+		Errors:               []TaskError{},
+		CollectionErrorCount: v.ErrorCount,
+		CollectionTruncated:  v.Truncated,
+		maxErrors:            v.maxErrors,
+		shared:               true,
+	}
+	if v.Status == RuleStatusCancelled || v.Status == RuleStatusTimeout {
+		rv.Valid = false
+	}
+
+	for _, err := range v.Errors {
+		if err.Document == "" || err.Document == name {
+			rv.AddError(err)
+		}
+	}
+
+	return rv
+}
+
+func cancelledRuleValidation(name string) *RuleValidation {
+	return &RuleValidation{
+		Name:   name,
+		Valid:  false,
+		Status: RuleStatusCancelled,
+		Errors: []TaskError{},
+	}
+}
+
+func skippedRuleValidation(name, reason string) *RuleValidation {
+	return &RuleValidation{
+		Name:   name,
+		Valid:  true,
+		Status: RuleStatusSkipped,
+		Reason: reason,
+		Errors: []TaskError{},
 	}
 }
 
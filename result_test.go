@@ -0,0 +1,71 @@
+package greenlight
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestForDocument(t *testing.T) {
+	coll := &RuleValidation{
+		Name:      "everyLineIsReferenced",
+		Valid:     true,
+		Status:    RuleStatusComplete,
+		Errors:    []TaskError{},
+		maxErrors: 2,
+	}
+	coll.AddError(TaskError{Message: "a1", Document: "a.xml"})
+	coll.AddError(TaskError{Message: "a2", Document: "a.xml"})
+	coll.AddError(TaskError{Message: "b1", Document: "b.xml"})
+
+	tests := []struct {
+		document string
+		errors   int
+		valid    bool
+	}{
+		{"a.xml", 2, false},
+		{"b.xml", 0, true}, // b1 was left out of the collection results
+		{"c.xml", 0, true},
+	}
+
+	for _, tt := range tests {
+		rv := coll.forDocument(tt.document)
+		if len(rv.Errors) != tt.errors || rv.ErrorCount != tt.errors {
+			t.Errorf("%s: got %d errors (count %d), want %d", tt.document, len(rv.Errors), rv.ErrorCount, tt.errors)
+		}
+		if rv.Valid != tt.valid {
+			t.Errorf("%s: got valid %t, want %t", tt.document, rv.Valid, tt.valid)
+		}
+		if rv.Truncated {
+			t.Errorf("%s: expected the document not to be truncated", tt.document)
+		}
+		if rv.CollectionErrorCount != 3 || !rv.CollectionTruncated {
+			t.Errorf("%s: got collection totals (%d, %t), want (3, true)", tt.document, rv.CollectionErrorCount, rv.CollectionTruncated)
+		}
+	}
+}
+
+func TestParamsMarshalXML(t *testing.T) {
+	te := TaskError{
+		Message: "missing name",
+		Params: Params{
+			"type":     "StopPlace",
+			"expected": []string{"Name", "ShortName"},
+			"count":    2,
+		},
+	}
+
+	buf, err := xml.Marshal(te)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<Params>` +
+		`<param name="count">2</param>` +
+		`<param name="expected"><value>Name</value><value>ShortName</value></param>` +
+		`<param name="type">StopPlace</param>` +
+		`</Params>`
+	if !strings.Contains(string(buf), want) {
+		t.Errorf("got %s, want it to contain %s", buf, want)
+	}
+}
@@ -0,0 +1,216 @@
+package greenlight
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/concreteit/greenlight/internal"
+	"github.com/concreteit/greenlight/js"
+	"github.com/concreteit/greenlight/xml"
+)
+
+// Rule is a validation rule, either a script (see Validation.AddScript) or a
+// rule implemented in Go. Rules are configured, ordered and reported the same
+// way regardless of how they are implemented.
+type Rule interface {
+	Name() string
+	Description() string
+
+	// Scope returns js.ScopeDocument or js.ScopeCollection
+	Scope() string
+
+	// Checksum identifies the implementation of the rule, it must change
+	// whenever its findings may change (a version string is enough for rules
+	// implemented in Go) as it is part of the key of cached results
+	Checksum() string
+
+	// Validate runs the rule against doc, or against coll only if the rule is
+	// run with scope collection (doc is nil). cfg is the config of the rule in
+	// the profile, or nil. If ctx is done the rule must stop and return the
+	// context error. A document rule may read coll as well, cached results of
+	// the rule are then only reused while every document is unchanged.
+	Validate(ctx context.Context, cfg map[string]interface{}, doc *xml.Document, coll *xml.Collection) (RuleResult, error)
+}
+
+// ConfigurableRule is implemented by rules declaring the options accepted in
+// their config. The config of other rules, or of rules returning a nil schema,
+// is only checked for reserved keys.
+type ConfigurableRule interface {
+	ConfigSchema() js.ConfigSchema
+}
+
+// reservedConfigKeys are the config keys handled by the validation itself,
+// accepted by every rule
+var reservedConfigKeys = []string{"timeout", "severity", "maxErrors", "scope", "requires", "after"}
+
+// ValidateRuleConfig checks cfg against the reserved config keys and the
+// config schema of the rule, if any
+func ValidateRuleConfig(rule Rule, cfg map[string]interface{}) error {
+	msgs := []string{}
+	if _, err := scriptTimeout(cfg, 0); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid value for 'timeout': %s", err))
+	}
+	if _, err := scriptSeverity(cfg); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid value for 'severity': %s", err))
+	}
+	if _, err := scriptMaxErrors(cfg, 0); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid value for 'maxErrors': %s", err))
+	}
+	if _, err := scriptScope(ScriptEnv{rule: rule, cfg: cfg}); err != nil {
+		msgs = append(msgs, fmt.Sprintf("invalid value for 'scope': %s", err))
+	}
+	if _, _, err := scriptDependencies(cfg); err != nil {
+		msgs = append(msgs, err.Error())
+	}
+	if r, ok := rule.(ConfigurableRule); ok && r.ConfigSchema() != nil {
+		if err := r.ConfigSchema().Validate(cfg, reservedConfigKeys...); err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("invalid config for rule '%s': %s", rule.Name(), strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
+
+// ValidateScriptConfig checks cfg the same way as ValidateRuleConfig for a
+// script added with Validation.AddScript
+func ValidateScriptConfig(script *js.Script, cfg map[string]interface{}) error {
+	return ValidateRuleConfig(&scriptRule{script: script}, cfg)
+}
+
+type RuleResult struct {
+	Errors []TaskError
+
+	// number of findings found but not returned by the rule
+	Omitted int
+}
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]Rule{}
+)
+
+// RegisterRule makes a rule available by name, e.g. to profiles. It panics if
+// a rule with the same name is already registered.
+func RegisterRule(rule Rule) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if _, ok := rules[rule.Name()]; ok {
+		panic(fmt.Sprintf("greenlight: rule '%s' is already registered", rule.Name()))
+	}
+	rules[rule.Name()] = rule
+}
+
+// LookupRule returns the registered rule with the given name
+func LookupRule(name string) (Rule, bool) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	rule, ok := rules[name]
+	return rule, ok
+}
+
+// RuleNames returns the sorted names of all registered rules
+func RuleNames() []string {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	names := []string{}
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// scriptRule runs a script as a rule
+type scriptRule struct {
+	script  *js.Script
+	emitter *internal.Emitter
+}
+
+func (r *scriptRule) Name() string { return r.script.Name() }
+
+func (r *scriptRule) Description() string { return r.script.Description() }
+
+func (r *scriptRule) Scope() string { return r.script.Scope() }
+
+func (r *scriptRule) Checksum() string { return r.script.Checksum() }
+
+func (r *scriptRule) ConfigSchema() js.ConfigSchema { return r.script.ConfigSchema() }
+
+func (r *scriptRule) Validate(ctx context.Context, cfg map[string]interface{}, doc *xml.Document, coll *xml.Collection) (RuleResult, error) {
+	var res internal.Result
+	if doc == nil {
+		res = r.script.RunCollection(ctx, r.emitter, coll, cfg)
+	} else {
+		res = r.script.Run(ctx, doc.Name, doc, r.emitter, coll, cfg)
+	}
+	if res.IsErr() {
+		return RuleResult{}, res.Message()
+	}
+
+	if res.Get() == nil {
+		return RuleResult{}, fmt.Errorf("invalid response from task")
+	}
+	sr, ok := res.Get().(js.ScriptResult)
+	if !ok {
+		return RuleResult{}, fmt.Errorf("invalid response from task")
+	}
+
+	rr := RuleResult{
+		Errors:  []TaskError{},
+		Omitted: sr.Omitted,
+	}
+	for _, err := range sr.Errors {
+		te := TaskError{
+			Message: err.Message,
+			Type:    err.Type,
+		}
+		if s, err := ParseSeverity(err.Severity); err == nil {
+			te.Severity = s
+		}
+
+		if err.Extra != nil && err.Extra["line"] != nil {
+			te.Line = mustInt(err.Extra["line"])
+		}
+		if err.Extra != nil && err.Extra["column"] != nil {
+			te.Column = mustInt(err.Extra["column"])
+		}
+		if err.Extra != nil {
+			if document, ok := err.Extra["document"].(string); ok {
+				te.Document = document
+			}
+			if path, ok := err.Extra["path"].(string); ok {
+				te.Path = path
+			}
+			if id, ok := err.Extra["id"].(string); ok {
+				te.ID = id
+			}
+			if version, ok := err.Extra["version"].(string); ok {
+				te.Version = version
+			}
+			if code, ok := err.Extra["code"].(string); ok {
+				te.Code = code
+			}
+			switch params := err.Extra["params"].(type) {
+			case map[string]interface{}:
+				te.Params = params
+			case internal.M:
+				te.Params = Params(params)
+			}
+		}
+
+		rr.Errors = append(rr.Errors, te)
+	}
+
+	return rr, nil
+}
@@ -0,0 +1,57 @@
+package greenlight
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/concreteit/greenlight/js"
+)
+
+func TestValidateRuleConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		cfg    map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "no schema accepts any option",
+			source: `const name = "noSchema"; function main(ctx) { return []; }`,
+			cfg:    map[string]interface{}{"distance": 100, "timeout": "1s"},
+		},
+		{
+			name:   "no schema checks reserved keys",
+			source: `const name = "noSchema"; function main(ctx) { return []; }`,
+			cfg:    map[string]interface{}{"timeout": "soon"},
+			err:    "invalid value for 'timeout'",
+		},
+		{
+			name:   "schema accepts declared options",
+			source: `const name = "schema"; const configSchema = { distance: { type: "number", default: 500 } }; function main(ctx) { return []; }`,
+			cfg:    map[string]interface{}{"distance": 100, "severity": "warning"},
+		},
+		{
+			name:   "schema rejects unknown options",
+			source: `const name = "schema"; const configSchema = { distance: { type: "number", default: 500 } }; function main(ctx) { return []; }`,
+			cfg:    map[string]interface{}{"distanse": 100},
+			err:    "distanse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := js.NewScript(filepath.Join(t.TempDir(), "main.js"), []byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = ValidateRuleConfig(&scriptRule{script: s}, tt.cfg)
+			if tt.err == "" && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			} else if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package greenlight
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/concreteit/greenlight/internal"
+	"github.com/concreteit/greenlight/xml"
+)
+
+const (
+	// DefaultSourceContextLimit is the number of findings per rule that get a
+	// source context unless set otherwise
+	DefaultSourceContextLimit = 10
+
+	maxSourceLineLength = 240
+)
+
+// SourceLine is a line of the document around a finding
+type SourceLine struct {
+	Line int    `json:"line" xml:"line,attr"`
+	Text string `json:"text" xml:",chardata"`
+}
+
+// addSourceContext attaches the lines of filePath surrounding each finding of
+// rvs, at most limit findings per rule. The file is read once, up to the last
+// line needed.
+func addSourceContext(filePath string, rvs []*RuleValidation, around, limit int) error {
+	wanted := map[int]bool{}
+	last := 0
+	for _, rv := range rvs {
+		n := 0
+		for _, err := range rv.Errors {
+			if n >= limit {
+				break
+			} else if err.Line <= 0 {
+				continue
+			}
+			for l := err.Line - around; l <= err.Line+around; l++ {
+				wanted[l] = true
+			}
+			if err.Line+around > last {
+				last = err.Line + around
+			}
+			n++
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	lines := map[int]string{}
+	br := bufio.NewReader(f)
+	for l := 1; l <= last; l++ {
+		text, err := readSourceLine(br)
+		if wanted[l] {
+			lines[l] = text
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	for _, rv := range rvs {
+		n := 0
+		for i, err := range rv.Errors {
+			if n >= limit {
+				break
+			} else if err.Line <= 0 {
+				continue
+			}
+			ctx := []SourceLine{}
+			for l := err.Line - around; l <= err.Line+around; l++ {
+				if text, ok := lines[l]; ok {
+					ctx = append(ctx, SourceLine{Line: l, Text: text})
+				}
+			}
+			rv.Errors[i].Context = ctx
+			n++
+		}
+	}
+
+	return nil
+}
+
+// attachSourceContext adds the source context of doc to the findings of rv if
+// enabled. It must be done before rv is streamed, a streamed rule isn't
+// modified anymore.
+func (v *Validation) attachSourceContext(name string, doc *xml.Document, rv *RuleValidation) {
+	if v.sourceContext <= 0 || doc == nil {
+		return
+	}
+	if err := addSourceContext(doc.FilePath, []*RuleValidation{rv}, v.sourceContext, v.sourceContextLimit); err != nil {
+		v.Emit(internal.EventTypeLog, internal.M{
+			"level":   "warn",
+			"message": fmt.Sprintf("unable to read source context of document '%s': %s", name, err),
+		})
+	}
+}
+
+// readSourceLine reads a single line, truncating it if it's too long
+func readSourceLine(br *bufio.Reader) (string, error) {
+	var sb strings.Builder
+	truncated := false
+	for {
+		buf, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return sb.String(), err
+		}
+		if !truncated {
+			if sb.Len()+len(buf) > maxSourceLineLength {
+				sb.Write(buf[:maxSourceLineLength-sb.Len()])
+				sb.WriteString("...")
+				truncated = true
+			} else {
+				sb.Write(buf)
+			}
+		}
+		if !isPrefix {
+			return sb.String(), nil
+		}
+	}
+}
+
+// formatSourceContext renders the source context as text, one line of the
+// document per line
+func formatSourceContext(ctx []SourceLine) string {
+	lines := make([]string, len(ctx))
+	for i, l := range ctx {
+		lines[i] = strings.TrimRight(fmt.Sprintf("%6d | %s", l.Line, l.Text), " ")
+	}
+
+	return strings.Join(lines, "\n")
+}
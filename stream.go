@@ -0,0 +1,72 @@
+package greenlight
+
+import (
+	"context"
+)
+
+type StreamResultType string
+
+const (
+	StreamResultTypeRule     StreamResultType = "rule"
+	StreamResultTypeDocument StreamResultType = "document"
+	StreamResultTypeError    StreamResultType = "error"
+)
+
+// StreamResult is a single update delivered by Validation.ValidateStream
+type StreamResult struct {
+	Type StreamResultType
+
+	// Document is the name of the document the update belongs to
+	Document string
+
+	// Rule is set for updates of type "rule"
+	Rule *RuleValidation
+
+	// Result is set for updates of type "document"
+	Result *ValidationResult
+
+	// Err is set for updates of type "error"
+	Err error
+}
+
+type streamHandler func(r StreamResult)
+
+func (h streamHandler) rule(document string, rv *RuleValidation) {
+	if h != nil {
+		h(StreamResult{
+			Type:     StreamResultTypeRule,
+			Document: document,
+			Rule:     rv,
+		})
+	}
+}
+
+func (h streamHandler) document(vr *ValidationResult) {
+	if h != nil {
+		h(StreamResult{
+			Type:     StreamResultTypeDocument,
+			Document: vr.Name,
+			Result:   vr,
+		})
+	}
+}
+
+// ValidateStream runs the validation in the background, delivering every
+// RuleValidation and ValidationResult as soon as they are complete. The
+// channel is closed when the validation is done and must be drained until
+// then. If the validation fails, or ctx is cancelled, the last update
+// delivered is of type "error" (any partial results are delivered before it).
+func (v *Validation) ValidateStream(ctx context.Context) <-chan StreamResult {
+	ch := make(chan StreamResult)
+
+	go func() {
+		defer close(ch)
+
+		send := func(r StreamResult) { ch <- r }
+		if _, err := v.validate(ctx, send); err != nil {
+			send(StreamResult{Type: StreamResultTypeError, Err: err})
+		}
+	}()
+
+	return ch
+}
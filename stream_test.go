@@ -0,0 +1,88 @@
+package greenlight
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/concreteit/greenlight/xml"
+)
+
+// lineRule reports a finding at every Line of the document, or of every
+// document of the collection
+type lineRule struct {
+	name  string
+	scope string
+}
+
+func (r *lineRule) Name() string        { return r.name }
+func (r *lineRule) Description() string { return "" }
+func (r *lineRule) Scope() string       { return r.scope }
+func (r *lineRule) Checksum() string    { return "1" }
+
+func (r *lineRule) Validate(ctx context.Context, cfg map[string]interface{}, doc *xml.Document, coll *xml.Collection) (RuleResult, error) {
+	res := RuleResult{Errors: []TaskError{}}
+	nodes := []xml.Node{doc}
+	if doc == nil {
+		nodes = coll.Nodes()
+	}
+	for _, node := range nodes {
+		name := ""
+		if d, ok := node.(*xml.Document); ok {
+			name = d.Name
+		}
+		lines, _ := node.Find("//Line").Get().([]xml.Node)
+		for _, line := range lines {
+			res.Errors = append(res.Errors, TaskError{Message: "line", Line: line.Line(), Document: name})
+		}
+	}
+
+	return res, nil
+}
+
+func TestValidateStreamSourceContext(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"a.xml", "b.xml", "c.xml"}
+	for _, name := range names {
+		writeFile(t, filepath.Join(dir, name), "<Lines>\n  <Line/>\n  <Line/>\n</Lines>\n")
+	}
+
+	v, err := NewValidation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.SetSourceContext(1, 0)
+	v.AddRule(&lineRule{name: "documentLines", scope: "document"}, nil)
+	v.AddRule(&lineRule{name: "collectionLines", scope: "collection"}, nil)
+	for _, name := range names {
+		if err := v.AddFile(name, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules := 0
+	for r := range v.ValidateStream(context.Background()) {
+		switch r.Type {
+		case StreamResultTypeError:
+			t.Fatal(r.Err)
+		case StreamResultTypeRule:
+			rules++
+			// read the streamed rule while the validation is still running
+			if _, err := json.Marshal(r.Rule); err != nil {
+				t.Fatal(err)
+			}
+			if len(r.Rule.Errors) != 2 {
+				t.Fatalf("%s of %s: got %d findings, want 2", r.Rule.Name, r.Document, len(r.Rule.Errors))
+			}
+			for _, err := range r.Rule.Errors {
+				if len(err.Context) != 3 {
+					t.Errorf("%s of %s: got %d lines of context at line %d, want 3", r.Rule.Name, r.Document, len(err.Context), err.Line)
+				}
+			}
+		}
+	}
+	if rules != 2*len(names) {
+		t.Errorf("got %d rules streamed, want %d", rules, 2*len(names))
+	}
+}
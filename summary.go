@@ -0,0 +1,215 @@
+package greenlight
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Summary aggregates the results of a validation
+type Summary struct {
+	DocumentCount   int `json:"document_count" xml:"documentCount,attr"`
+	ValidCount      int `json:"valid_count" xml:"validCount,attr"`
+	InvalidCount    int `json:"invalid_count" xml:"invalidCount,attr"`
+	CancelledCount  int `json:"cancelled_count,omitempty" xml:"cancelledCount,attr,omitempty"`
+	ErrorCount      int `json:"error_count" xml:"errorCount,attr"`
+	SuppressedCount int `json:"suppressed_count,omitempty" xml:"suppressedCount,attr,omitempty"`
+
+	// wall-clock time from the start of the first rule to the end of the last
+	DurationMs int64 `json:"duration_ms" xml:"durationMs,attr"`
+
+	Rules     []*RuleSummary     `json:"rules" xml:"Rule"`
+	Documents []*DocumentSummary `json:"documents" xml:"Document"`
+
+	// findings by type and severity, only findings stored on a rule are
+	// counted (findings beyond the error cap of a rule lack these details)
+	Types      []*FindingCount `json:"types" xml:"Type"`
+	Severities []*FindingCount `json:"severities" xml:"Severity"`
+}
+
+type RuleSummary struct {
+	Name            string `json:"name" xml:"name,attr"`
+	ValidCount      int    `json:"valid_count" xml:"validCount,attr"`
+	InvalidCount    int    `json:"invalid_count" xml:"invalidCount,attr"`
+	ErrorCount      int    `json:"error_count" xml:"errorCount,attr"`
+	SuppressedCount int    `json:"suppressed_count,omitempty" xml:"suppressedCount,attr,omitempty"`
+
+	// total time spent running the rule and the time of its slowest document
+	DurationMs    int64 `json:"duration_ms" xml:"durationMs,attr"`
+	MaxDurationMs int64 `json:"max_duration_ms" xml:"maxDurationMs,attr"`
+}
+
+type DocumentSummary struct {
+	Name       string `json:"name" xml:"name,attr"`
+	Valid      bool   `json:"valid" xml:"valid,attr"`
+	ErrorCount int    `json:"error_count" xml:"errorCount,attr"`
+	DurationMs int64  `json:"duration_ms" xml:"durationMs,attr"`
+}
+
+type FindingCount struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Count int    `json:"count" xml:"count,attr"`
+}
+
+// NewSummary aggregates results, rules and documents are listed in the order
+// they first appear in results
+func NewSummary(results []*ValidationResult) *Summary {
+	s := &Summary{
+		Rules:      []*RuleSummary{},
+		Documents:  []*DocumentSummary{},
+		Types:      []*FindingCount{},
+		Severities: []*FindingCount{},
+	}
+
+	var start, stop time.Time
+	ruleMap := map[string]*RuleSummary{}
+	types := map[string]int{}
+	severities := map[string]int{}
+	for _, r := range results {
+		s.DocumentCount++
+		if r.Valid {
+			s.ValidCount++
+		} else {
+			s.InvalidCount++
+		}
+		if r.Cancelled {
+			s.CancelledCount++
+		}
+		s.SuppressedCount += r.SuppressedCount
+
+		doc := &DocumentSummary{
+			Name:       r.Name,
+			Valid:      r.Valid,
+			DurationMs: r.Duration.Milliseconds(),
+		}
+		s.Documents = append(s.Documents, doc)
+
+		for _, rv := range r.ValidationRules {
+			rs, ok := ruleMap[rv.Name]
+			if !ok {
+				rs = &RuleSummary{Name: rv.Name}
+				ruleMap[rv.Name] = rs
+				s.Rules = append(s.Rules, rs)
+			}
+
+			if rv.Valid {
+				rs.ValidCount++
+			} else {
+				rs.InvalidCount++
+			}
+			rs.ErrorCount += rv.ErrorCount
+			rs.SuppressedCount += rv.Suppressed
+			doc.ErrorCount += rv.ErrorCount
+			s.ErrorCount += rv.ErrorCount
+
+			// collection rules run once for every document, their duration is
+			// shared by all documents and only counted once
+			d := rv.Duration.Milliseconds()
+			if !rv.shared {
+				rs.DurationMs += d
+			} else if d > rs.DurationMs {
+				rs.DurationMs = d
+			}
+			if d > rs.MaxDurationMs {
+				rs.MaxDurationMs = d
+			}
+
+			if !rv.Start.IsZero() && (start.IsZero() || rv.Start.Before(start)) {
+				start = rv.Start
+			}
+			if rv.Stop.After(stop) {
+				stop = rv.Stop
+			}
+
+			for _, err := range rv.Errors {
+				t := err.Type
+				if t == "" {
+					t = "general"
+				}
+				types[t]++
+				severities[string(err.Severity)]++
+			}
+		}
+	}
+
+	if !start.IsZero() {
+		s.DurationMs = stop.Sub(start).Milliseconds()
+	}
+	s.Types = findingCounts(types)
+	s.Severities = findingCounts(severities)
+
+	return s
+}
+
+// findingCounts returns the counts of m sorted by count, most frequent first
+func findingCounts(m map[string]int) []*FindingCount {
+	res := []*FindingCount{}
+	for k, v := range m {
+		res = append(res, &FindingCount{Name: k, Count: v})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+
+	return res
+}
+
+func (s *Summary) CsvRecords(includeHeader bool) [][]string {
+	res := [][]string{}
+	header := []string{
+		"section",
+		"name",
+		"count",
+		"valid_count",
+		"invalid_count",
+		"duration_ms",
+	}
+
+	if includeHeader {
+		res = append(res, header)
+	}
+
+	res = append(res, []string{
+		"total",
+		"",
+		fmt.Sprintf("%d", s.ErrorCount),
+		fmt.Sprintf("%d", s.ValidCount),
+		fmt.Sprintf("%d", s.InvalidCount),
+		fmt.Sprintf("%d", s.DurationMs),
+	})
+	for _, r := range s.Rules {
+		res = append(res, []string{
+			"rule",
+			r.Name,
+			fmt.Sprintf("%d", r.ErrorCount),
+			fmt.Sprintf("%d", r.ValidCount),
+			fmt.Sprintf("%d", r.InvalidCount),
+			fmt.Sprintf("%d", r.DurationMs),
+		})
+	}
+	for _, d := range s.Documents {
+		valid, invalid := "1", "0"
+		if !d.Valid {
+			valid, invalid = "0", "1"
+		}
+		res = append(res, []string{
+			"document",
+			d.Name,
+			fmt.Sprintf("%d", d.ErrorCount),
+			valid,
+			invalid,
+			fmt.Sprintf("%d", d.DurationMs),
+		})
+	}
+	for _, t := range s.Types {
+		res = append(res, []string{"type", t.Name, fmt.Sprintf("%d", t.Count), "", "", ""})
+	}
+	for _, t := range s.Severities {
+		res = append(res, []string{"severity", t.Name, fmt.Sprintf("%d", t.Count), "", "", ""})
+	}
+
+	return res
+}
@@ -0,0 +1,33 @@
+package greenlight
+
+import (
+	"github.com/concreteit/greenlight/xml"
+)
+
+// suppressInline drops the findings of rv acknowledged by a greenlight-ignore
+// comment in doc, moving them to the suppressed findings of the rule. A
+// document that can't be parsed has no suppressions, the rules already report
+// why.
+func suppressInline(doc *xml.Document, rv *RuleValidation) {
+	if len(rv.Errors) == 0 {
+		return
+	}
+
+	suppressions, err := doc.Suppressions()
+	if err != nil || len(suppressions) == 0 {
+		return
+	}
+
+	removed := rv.suppress(func(err TaskError) bool {
+		if err.Line == 0 || (err.Document != "" && err.Document != doc.Name) {
+			return false
+		}
+		for _, s := range suppressions {
+			if s.Contains(rv.Name, err.Line) {
+				return true
+			}
+		}
+		return false
+	})
+	rv.SuppressedErrors = append(rv.SuppressedErrors, removed...)
+}
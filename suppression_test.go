@@ -0,0 +1,114 @@
+package greenlight
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/concreteit/greenlight/xml"
+)
+
+func TestSuppressInline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.xml")
+	writeFile(t, path, `<?xml version="1.0"?>
+<!-- greenlight-ignore: everyLineIsReferenced -->
+<PublicationDelivery>
+  <!-- greenlight-ignore: everyLineHasAName, everyStopIsReferenced -->
+  <Line id="1">
+    <Name/>
+  </Line>
+  <Line id="2"/>
+</PublicationDelivery>
+`)
+	doc, err := xml.NewDocument("a.xml", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	tests := []struct {
+		name       string
+		errors     []TaskError
+		suppressed []int // lines of the suppressed findings
+	}{
+		{
+			// from the comment through the end of the element
+			name:       "everyLineHasAName",
+			errors:     []TaskError{{Line: 3}, {Line: 4}, {Line: 5}, {Line: 7}, {Line: 8}, {}},
+			suppressed: []int{4, 5, 7},
+		},
+		{
+			name:       "everyStopIsReferenced",
+			errors:     []TaskError{{Line: 6}},
+			suppressed: []int{6},
+		},
+		{
+			// suppressed by the comment in the prolog, for the whole document
+			name:       "everyLineIsReferenced",
+			errors:     []TaskError{{Line: 3}, {Line: 8}, {Line: 9}},
+			suppressed: []int{3, 8, 9},
+		},
+		{
+			name:   "frameDefaultsHaveALocaleAndTimeZone",
+			errors: []TaskError{{Line: 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		rv := &RuleValidation{Name: tt.name, Valid: true, Status: RuleStatusComplete, Errors: []TaskError{}}
+		for _, err := range tt.errors {
+			err.Severity = SeverityError
+			rv.AddError(err)
+		}
+
+		suppressInline(doc, rv)
+		if len(rv.SuppressedErrors) != len(tt.suppressed) || rv.Suppressed != len(tt.suppressed) {
+			t.Errorf("%s: got %d suppressed findings, want %d", tt.name, len(rv.SuppressedErrors), len(tt.suppressed))
+			continue
+		}
+		for i, line := range tt.suppressed {
+			if rv.SuppressedErrors[i].Line != line {
+				t.Errorf("%s: got suppressed finding at line %d, want %d", tt.name, rv.SuppressedErrors[i].Line, line)
+			}
+		}
+		if n := len(tt.errors) - len(tt.suppressed); len(rv.Errors) != n || rv.ErrorCount != n || rv.Valid != (n == 0) {
+			t.Errorf("%s: got %d findings (count %d, valid %t), want %d", tt.name, len(rv.Errors), rv.ErrorCount, rv.Valid, n)
+		}
+	}
+}
+
+func TestSuppressInlineCollection(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.xml")
+	writeFile(t, path, `<PublicationDelivery>
+  <!-- greenlight-ignore: everyLineIsReferenced -->
+  <Line id="1"/>
+</PublicationDelivery>
+`)
+	doc, err := xml.NewDocument("a.xml", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	coll := &RuleValidation{Name: "everyLineIsReferenced", Valid: true, Status: RuleStatusComplete, Errors: []TaskError{}}
+	coll.AddError(TaskError{Line: 3, Document: "a.xml", Severity: SeverityError})
+	coll.AddError(TaskError{Line: 3, Document: "b.xml", Severity: SeverityError})
+	// findings not tagged with a document belong to every document, without
+	// a line they can't be suppressed
+	coll.AddError(TaskError{Severity: SeverityError})
+
+	rv := coll.forDocument("a.xml")
+	suppressInline(doc, rv)
+	if len(rv.SuppressedErrors) != 1 || rv.SuppressedErrors[0].Document != "a.xml" {
+		t.Errorf("got suppressed findings %+v, want the finding of a.xml", rv.SuppressedErrors)
+	}
+	if len(rv.Errors) != 1 || rv.Errors[0].Document != "" || rv.Valid {
+		t.Errorf("got findings %+v (valid %t), want the finding of the collection", rv.Errors, rv.Valid)
+	}
+
+	// the findings of other documents are left to their own suppressions
+	rv = coll.forDocument("b.xml")
+	suppressInline(doc, rv)
+	if len(rv.SuppressedErrors) != 0 || len(rv.Errors) != 2 {
+		t.Errorf("got %d findings and %d suppressed for b.xml, want 2 and 0", len(rv.Errors), len(rv.SuppressedErrors))
+	}
+}
@@ -12,9 +12,19 @@ import (
 	gonanoid "github.com/matoous/go-nanoid/v2"
 )
 
+// Scheduler limits the number of tasks (documents, rules and script workers)
+// running at the same time, it may be shared by several validations
+type Scheduler = internal.Scheduler
+
+// NewScheduler creates a scheduler running at most workers tasks at the same
+// time, GOMAXPROCS if workers is zero or less
+func NewScheduler(workers int) *Scheduler {
+	return internal.NewScheduler(workers)
+}
+
 type ScriptEnv struct {
-	script *js.Script
-	cfg    map[string]interface{}
+	rule Rule
+	cfg  map[string]interface{}
 }
 
 type Validation struct {
@@ -23,6 +33,19 @@ type Validation struct {
 	documentMap  map[string]*xml.Document
 	documentColl *xml.Collection
 	scripts      map[string]ScriptEnv
+
+	// names of documents and scripts in the order they were added, results
+	// are returned in this order
+	documentNames []string
+	scriptNames   []string
+	ruleTimeout   time.Duration
+	maxErrors     int
+	cache         *Cache
+	digests       digestCache
+	scheduler     *Scheduler
+
+	sourceContext      int
+	sourceContextLimit int
 }
 
 func (v *Validation) Emit(t internal.EventType, data map[string]interface{}) {
@@ -38,6 +61,9 @@ func (v *Validation) Unsubscribe(id int) {
 }
 
 func (v *Validation) AddDocument(doc *xml.Document) error {
+	if _, ok := v.documentMap[doc.Name]; !ok {
+		v.documentNames = append(v.documentNames, doc.Name)
+	}
 	v.documentMap[doc.Name] = doc
 	v.documentColl.Add(doc)
 
@@ -54,26 +80,106 @@ func (v *Validation) AddFile(name, filePath string) error {
 }
 
 func (v *Validation) AddScript(script *js.Script, cfg map[string]interface{}) {
-	v.scripts[script.Name()] = ScriptEnv{
-		script: script,
-		cfg:    cfg,
+	v.AddRule(&scriptRule{
+		script:  script,
+		emitter: v.emitter,
+	}, cfg)
+}
+
+// AddRule adds a rule implemented in Go, it is run and reported the same way
+// as a script added with AddScript
+func (v *Validation) AddRule(rule Rule, cfg map[string]interface{}) {
+	if _, ok := v.scripts[rule.Name()]; !ok {
+		v.scriptNames = append(v.scriptNames, rule.Name())
+	}
+	v.scripts[rule.Name()] = ScriptEnv{
+		rule: rule,
+		cfg:  cfg,
+	}
+}
+
+// SetRuleTimeout sets the default wall-clock budget of a single rule run on a
+// single document, zero means no limit. A budget configured on the script
+// itself (config key "timeout") takes precedence.
+func (v *Validation) SetRuleTimeout(d time.Duration) {
+	v.ruleTimeout = d
+}
+
+// SetMaxErrors sets the default number of findings stored per rule and
+// document, zero means DefaultMaxErrors. A limit configured on the script
+// itself (config key "maxErrors") takes precedence. Findings beyond the limit
+// are counted but not stored, and the rule is marked as truncated.
+func (v *Validation) SetMaxErrors(n int) {
+	v.maxErrors = n
+}
+
+// SetScheduler sets the scheduler every document, rule and script worker of
+// the validation is run with. If none is set, a scheduler shared by the whole
+// process running GOMAXPROCS tasks at the same time is used.
+func (v *Validation) SetScheduler(s *Scheduler) {
+	v.scheduler = s
+}
+
+// SetCache enables caching of document script results, nil disables it
+func (v *Validation) SetCache(c *Cache) {
+	v.cache = c
+}
+
+// SetSourceContext attaches the given number of lines of the document before
+// and after each finding, zero disables it. Only the first limit findings of a
+// rule get a source context (DefaultSourceContextLimit if limit is zero).
+func (v *Validation) SetSourceContext(lines, limit int) {
+	if limit <= 0 {
+		limit = DefaultSourceContextLimit
 	}
+	v.sourceContext = lines
+	v.sourceContextLimit = limit
 }
 
+// Validate runs every script against every document. If ctx is cancelled or
+// its deadline is exceeded before the validation is complete, pending work is
+// skipped, running scripts are interrupted and the partial results are returned
+// together with the context error. Results that could not be completed are
+// marked as cancelled. Documents, and the rules of each document, are returned
+// in the order they were added.
 func (v *Validation) Validate(ctx context.Context) ([]*ValidationResult, error) {
+	return v.validate(ctx, nil)
+}
+
+func (v *Validation) validate(ctx context.Context, handler streamHandler) ([]*ValidationResult, error) {
 	emitData := internal.M{
 		"documentCount": len(v.documentMap),
 		"scriptCount":   len(v.scripts),
 	}
+	if v.scheduler != nil {
+		ctx = internal.WithScheduler(ctx, v.scheduler)
+	}
+
 	v.Emit(internal.EventTypeValidationStart, emitData)
 	defer v.emitter.Close()
 	defer v.Emit(internal.EventTypeValidationStop, emitData)
 
-	queue := internal.NewQueue()
-	for name, doc := range v.documentMap {
-		queue.Add(func(name string, doc *xml.Document) internal.Task {
-			return func(id int) internal.Result {
+	levels, err := v.scriptLevels(js.ScopeDocument)
+	if err != nil {
+		return nil, err
+	}
+	collLevels, err := v.scriptLevels(js.ScopeCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	// collection scripts are run once, before any of the document scripts
+	collMap := map[string]*RuleValidation{}
+	if err := v.runLevels(ctx, "", nil, collLevels, collMap, nil); err != nil {
+		return nil, err
+	}
+
+	queue := internal.NewQueue[*ValidationResult]()
+	for _, name := range v.documentNames {
+		queue.Add(func(name string, doc *xml.Document) internal.Task[*ValidationResult] {
+			return func(ctx context.Context, id int) (*ValidationResult, error) {
 				defer doc.Close()
+				start := time.Now()
 				emitData := internal.M{
 					"document":    name,
 					"scriptCount": len(v.scripts),
@@ -86,92 +192,235 @@ func (v *Validation) Validate(ctx context.Context) ([]*ValidationResult, error)
 					Valid:           true,
 					ValidationRules: []*RuleValidation{},
 				}
-				or := v.validateDocument(name, doc)
-				for _, r := range or {
-					if r.IsErr() {
-						return internal.NewResult(nil, r.Message())
+				rvMap := map[string]*RuleValidation{}
+				for _, scriptName := range v.scriptNames {
+					if rv, ok := collMap[scriptName]; ok {
+						rv = rv.forDocument(name)
+						suppressInline(doc, rv)
+						v.attachSourceContext(name, doc, rv)
+						rvMap[scriptName] = rv
+						handler.rule(name, rv)
+					}
+				}
+
+				rvs, err := v.validateDocument(ctx, name, doc, levels, rvMap, handler)
+				if err != nil {
+					return nil, err
+				}
+				for _, rv := range rvs {
+					res.ValidationRules = append(res.ValidationRules, rv)
+
+					if !rv.Valid {
+						res.Valid = false
 					}
-					if v, ok := r.Get().(*RuleValidation); !ok || v == nil {
-						return internal.NewResult(nil, fmt.Errorf("expected '%+v' to be of type '*RuleValidation' in document '%s'", r.Get(), name))
-					} else {
-						sort.Slice(v.Errors, func(i, j int) bool {
-							return v.Errors[i].Line < v.Errors[j].Line
-						})
-						res.ValidationRules = append(res.ValidationRules, v)
-
-						if !v.Valid {
-							res.Valid = false
-						}
+					if rv.Status == RuleStatusCancelled {
+						res.Cancelled = true
 					}
+					res.SuppressedCount += rv.Suppressed
 				}
+				res.Duration = time.Since(start)
+				handler.document(res)
 
-				return internal.NewResult(res, nil)
+				return res, nil
 			}
-		}(name, doc))
+		}(name, v.documentMap[name]))
 	}
 
 	res := []*ValidationResult{}
-	for _, r := range queue.Run() {
-		if r.IsErr() {
-			return nil, r.Message()
+	for i, r := range queue.Run(ctx) {
+		if r.Err != nil && !internal.IsCancelled(r.Err) {
+			return nil, r.Err
 		}
-		if vr, ok := r.Get().(*ValidationResult); !ok || vr == nil {
-			return nil, fmt.Errorf("expected '%+v' to be of type 'ValidationResult'", r.Get())
-		} else {
-			res = append(res, vr)
+
+		vr := r.Value
+		if vr == nil {
+			vr = &ValidationResult{
+				Name:            v.documentNames[i],
+				Valid:           false,
+				Cancelled:       true,
+				ValidationRules: []*RuleValidation{},
+			}
+			for _, scriptName := range v.scriptNames {
+				vr.ValidationRules = append(vr.ValidationRules, cancelledRuleValidation(scriptName))
+			}
+			handler.document(vr)
 		}
+		res = append(res, vr)
 	}
 
-	return res, nil
+	return res, ctx.Err()
 }
 
-func (v *Validation) validateDocument(name string, doc *xml.Document) []internal.Result {
-	queue := internal.NewQueue()
-	for _, script := range v.scripts {
-		queue.Add(func(env ScriptEnv) internal.Task {
-			return func(id int) internal.Result {
-				rv := &RuleValidation{
-					Start:  time.Now(),
-					Name:   env.script.Name(),
-					Valid:  true,
-					Errors: []TaskError{},
-				}
-				res := env.script.Run(name, doc, v.emitter, v.documentColl, env.cfg)
-				if res.IsErr() {
-					return res
-				}
-				if res.Get() == nil {
-					return internal.NewResult(nil, fmt.Errorf("invalid response from task"))
-				}
-				sr, ok := res.Get().(js.ScriptResult)
-				if !ok {
-					return internal.NewResult(nil, fmt.Errorf("invalid response from task"))
-				}
+// validateDocument runs the document scripts against doc, rvMap holds the
+// results of the collection scripts for the document
+func (v *Validation) validateDocument(
+	ctx context.Context,
+	name string,
+	doc *xml.Document,
+	levels [][]ScriptEnv,
+	rvMap map[string]*RuleValidation,
+	handler streamHandler,
+) ([]*RuleValidation, error) {
+	if err := v.runLevels(ctx, name, doc, levels, rvMap, handler); err != nil {
+		return nil, err
+	}
 
-				if sr.Errors != nil {
-					for _, err := range sr.Errors {
-						te := TaskError{
-							Message: err.Message,
-							Type:    err.Type,
-						}
+	rvs := []*RuleValidation{}
+	for _, scriptName := range v.scriptNames {
+		rv := rvMap[scriptName]
+		if rv == nil {
+			rv = cancelledRuleValidation(scriptName)
+			handler.rule(name, rv)
+		}
+		rvs = append(rvs, rv)
+	}
 
-						if err.Extra != nil && err.Extra["line"] != nil {
-							te.Line = mustInt(err.Extra["line"])
-						}
+	return rvs, nil
+}
+
+// runLevels runs the scripts level by level, storing the results in rvMap. If
+// doc is nil the scripts are run against the collection.
+func (v *Validation) runLevels(
+	ctx context.Context,
+	name string,
+	doc *xml.Document,
+	levels [][]ScriptEnv,
+	rvMap map[string]*RuleValidation,
+	handler streamHandler,
+) error {
+	for _, level := range levels {
+		queue := internal.NewQueue[*RuleValidation]()
+		for _, script := range level {
+			if reason := skipReason(script, v.scripts, rvMap); reason != "" {
+				rv := skippedRuleValidation(script.rule.Name(), reason)
+				rvMap[rv.Name] = rv
+				handler.rule(name, rv)
+				continue
+			}
 
-						rv.AddError(te)
+			queue.Add(func(env ScriptEnv) internal.Task[*RuleValidation] {
+				return func(ctx context.Context, id int) (*RuleValidation, error) {
+					rv, err := v.runScript(ctx, name, doc, env)
+					if err == nil {
+						v.attachSourceContext(name, doc, rv)
+						handler.rule(name, rv)
 					}
+					return rv, err
 				}
+			}(script))
+		}
 
-				rv.Stop = time.Now()
-				rv.Duration = rv.Stop.Sub(rv.Start)
+		for _, r := range queue.Run(ctx) {
+			if r.Err != nil {
+				if internal.IsCancelled(r.Err) {
+					continue
+				}
+				return r.Err
+			}
+			rvMap[r.Value.Name] = r.Value
+		}
+	}
 
-				return internal.NewResult(rv, nil)
+	return nil
+}
+
+// runScript runs a single rule against a single document, or the collection if
+// doc is nil
+func (v *Validation) runScript(ctx context.Context, name string, doc *xml.Document, env ScriptEnv) (*RuleValidation, error) {
+	cacheKey := ""
+	if v.cache != nil && doc != nil {
+		var rv *RuleValidation
+		if cacheKey, rv = v.cachedRuleValidation(doc, env); rv != nil {
+			return rv, nil
+		}
+	}
+
+	rv := &RuleValidation{
+		Start:  time.Now(),
+		Name:   env.rule.Name(),
+		Valid:  true,
+		Status: RuleStatusComplete,
+		Errors: []TaskError{},
+	}
+	timeout, err := scriptTimeout(env.cfg, v.ruleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout for script '%s': %w", env.rule.Name(), err)
+	}
+	severity, err := scriptSeverity(env.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid severity for script '%s': %w", env.rule.Name(), err)
+	}
+	if rv.maxErrors, err = scriptMaxErrors(env.cfg, v.maxErrors); err != nil {
+		return nil, fmt.Errorf("invalid maxErrors for script '%s': %w", env.rule.Name(), err)
+	}
+
+	var rctx context.Context
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		rctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		rctx, cancel = context.WithCancel(ctx)
+	}
+	rctx = internal.WithMaxErrors(rctx, rv.errorLimit())
+	// reads of the collection by a document rule are tracked, as the result
+	// of the rule then depends on the other documents (see Cache)
+	coll := v.documentColl
+	if doc != nil {
+		coll = coll.Tracked()
+	}
+	rr, err := env.rule.Validate(rctx, env.cfg, doc, coll)
+	cancel()
+
+	if err != nil {
+		if !internal.IsCancelled(err) {
+			return nil, err
+		}
+		rv.Valid = false
+		if ctx.Err() != nil {
+			rv.Status = RuleStatusCancelled
+		} else {
+			rv.Status = RuleStatusTimeout
+			rv.AddError(TaskError{
+				Code:    ErrorCodeTimeout,
+				Message: fmt.Sprintf("rule aborted after exceeding its time budget of %s", timeout),
+				Type:    string(RuleStatusTimeout),
+			})
+		}
+	} else {
+		for _, te := range rr.Errors {
+			if te.Code == "" {
+				te.Code = defaultErrorCode(te.Type)
+			}
+			if severity != "" {
+				te.Severity = severity
 			}
-		}(script))
+
+			rv.AddError(te)
+		}
+		rv.addOmitted(rr.Omitted)
+	}
+
+	sort.SliceStable(rv.Errors, func(i, j int) bool {
+		return rv.Errors[i].Line < rv.Errors[j].Line
+	})
+
+	if doc != nil {
+		suppressInline(doc, rv)
 	}
 
-	return queue.Run()
+	rv.Stop = time.Now()
+	rv.Duration = rv.Stop.Sub(rv.Start)
+
+	if cacheKey != "" && rv.Status == RuleStatusComplete {
+		if err := v.cacheRuleValidation(cacheKey, rv, coll.Used()); err != nil {
+			v.Emit(internal.EventTypeLog, internal.M{
+				"level":   "warn",
+				"message": fmt.Sprintf("unable to cache result of script '%s': %s", rv.Name, err),
+			})
+		}
+	}
+
+	return rv, nil
 }
 
 func NewValidation() (*Validation, error) {
@@ -193,6 +442,80 @@ func NewValidation() (*Validation, error) {
 	return ctx, nil
 }
 
+// scriptTimeout resolves the time budget of a script from its config, the value
+// can either be a duration string ("30s") or a number of seconds
+func scriptTimeout(cfg map[string]interface{}, fallback time.Duration) (time.Duration, error) {
+	if cfg == nil || cfg["timeout"] == nil {
+		return fallback, nil
+	}
+
+	switch t := cfg["timeout"].(type) {
+	case string:
+		return time.ParseDuration(t)
+	case float64:
+		return time.Duration(t * float64(time.Second)), nil
+	case int:
+		return time.Duration(t) * time.Second, nil
+	case int64:
+		return time.Duration(t) * time.Second, nil
+	}
+
+	return 0, fmt.Errorf("unexpected value '%v'", cfg["timeout"])
+}
+
+// scriptMaxErrors resolves the number of findings stored for a script from its
+// config
+func scriptMaxErrors(cfg map[string]interface{}, fallback int) (int, error) {
+	if cfg == nil || cfg["maxErrors"] == nil {
+		return fallback, nil
+	}
+
+	n := -1
+	switch t := cfg["maxErrors"].(type) {
+	case float64:
+		if t == float64(int(t)) {
+			n = int(t)
+		}
+	case int:
+		n = t
+	case int64:
+		n = int(t)
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("unexpected value '%v' (expected a positive integer)", cfg["maxErrors"])
+	}
+
+	return n, nil
+}
+
+// scriptScope resolves the scope of a script, the scope declared by the
+// script can be overridden with "scope" in its config
+func scriptScope(env ScriptEnv) (string, error) {
+	if env.cfg == nil || env.cfg["scope"] == nil {
+		return env.rule.Scope(), nil
+	}
+
+	if v, ok := env.cfg["scope"].(string); ok && (v == js.ScopeDocument || v == js.ScopeCollection) {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("unexpected value '%v' (expected one of \"%s\", \"%s\")", env.cfg["scope"], js.ScopeDocument, js.ScopeCollection)
+}
+
+// scriptSeverity resolves the severity override of a script from its config,
+// if set every finding of the script is reported using that severity
+func scriptSeverity(cfg map[string]interface{}) (Severity, error) {
+	if cfg == nil || cfg["severity"] == nil {
+		return "", nil
+	}
+
+	if v, ok := cfg["severity"].(string); ok {
+		return ParseSeverity(v)
+	}
+
+	return "", fmt.Errorf("unexpected value '%v'", cfg["severity"])
+}
+
 func mustInt(v interface{}) int {
 	i := 0
 	switch t := v.(type) {
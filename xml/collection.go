@@ -1,24 +1,88 @@
 package xml
 
 import (
+	"sync/atomic"
+
 	"github.com/concreteit/greenlight/internal"
 )
 
 type Collection struct {
-	data []Node
+	data    []Node
+	indexes *collectionIndexes
+
+	// set on views returned by Tracked, see Used
+	used *atomic.Bool
 }
 
 func NewCollection() *Collection {
 	return &Collection{
-		data: []Node{},
+		data:    []Node{},
+		indexes: &collectionIndexes{},
 	}
 }
 
+// Add adds a node to the collection. The indexes of the collection hold nodes
+// of its documents, those of a document are dropped once it is closed and
+// rebuilt on next use.
 func (s *Collection) Add(node Node) {
+	i := len(s.data)
 	s.data = append(s.data, node)
+	s.indexes.reset()
+	if doc, ok := node.(*Document); ok {
+		doc.onClose(func() { s.indexes.drop(i) })
+	}
+}
+
+// Tracked returns a view of the collection sharing its nodes and indexes,
+// which records whether the nodes of the collection are read through it
+func (c *Collection) Tracked() *Collection {
+	return &Collection{
+		data:    c.data,
+		indexes: c.indexes,
+		used:    &atomic.Bool{},
+	}
+}
+
+// Used reports whether the nodes of a view returned by Tracked have been read
+func (c *Collection) Used() bool {
+	return c.used != nil && c.used.Load()
+}
+
+func (c *Collection) touch() {
+	if c.used != nil {
+		c.used.Store(true)
+	}
+}
+
+// Nodes returns the nodes of the collection
+func (c *Collection) Nodes() []Node {
+	c.touch()
+	return c.data
+}
+
+// Index returns the nodes of every document matching q by the value of key,
+// see Document.Index. Indexes of documents are shared with the rules running
+// on each document.
+func (c *Collection) Index(q, key string) internal.Result { return internal.NewResult(c.index(q, key)) }
+
+func (c *Collection) index(q, key string) (*Index, error) {
+	c.touch()
+	return c.indexes.get(q, key, c.data)
+}
+
+// ById returns the first element of type t (e.g. "ServiceJourney") with the
+// given @id in any document
+func (c *Collection) ById(t, id string) internal.Result {
+	idx, err := c.index(byIdQuery(t), "@id")
+	if err != nil {
+		return internal.NewResult(nil, err)
+	}
+
+	return idx.First(id)
 }
 
 func (c *Collection) find(q string) ([]Node, error) {
+	c.touch()
 	nodes := []Node{}
 	for _, node := range c.data {
 		rnodes, err := node.find(q)
@@ -37,6 +101,7 @@ func (c *Collection) find(q string) ([]Node, error) {
 }
 
 func (c *Collection) first(q string) (Node, error) {
+	c.touch()
 	for _, node := range c.data {
 		rnodes, err := node.find(q)
 		if err != nil && err != ErrNodeNotFound {
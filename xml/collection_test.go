@@ -0,0 +1,56 @@
+package xml
+
+import (
+	"testing"
+)
+
+func TestCollectionIndexDroppedOnClose(t *testing.T) {
+	a, err := NewDocument("a.xml", writeTestFile(t, "a.xml", `<Delivery><Line id="a"/></Delivery>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewDocument("b.xml", writeTestFile(t, "b.xml", `<Delivery><Line id="b"/></Delivery>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollection()
+	c.Add(a)
+	c.Add(b)
+
+	idx, err := c.index("//Line", "@id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	beforeA, beforeB := idx.Get("a"), idx.Get("b")
+	if len(beforeA) != 1 || len(beforeB) != 1 {
+		t.Fatalf("expected a line of each document, got %d and %d", len(beforeA), len(beforeB))
+	}
+	if keys := idx.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("got keys %v, want [a b]", keys)
+	}
+
+	a.Close()
+	e := c.indexes.data["//Line\x00@id"]
+	if e == nil {
+		t.Fatal("expected the index of the collection to survive a document being closed")
+	}
+	if e.parts[0] != nil || e.parts[1] == nil {
+		t.Fatal("expected only the part of the closed document to be dropped")
+	}
+
+	// only the closed document is parsed again
+	idx, err = c.index("//Line", "@id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := idx.Get("a"); len(after) != 1 || after[0] == beforeA[0] {
+		t.Errorf("expected the line of the parsed document, got %v", after)
+	}
+	if after := idx.Get("b"); len(after) != 1 || after[0] != beforeB[0] {
+		t.Errorf("expected the line of the open document to be kept, got %v", after)
+	}
+	if !idx.Has("b") || idx.Has("c") || idx.Size() != 2 {
+		t.Errorf("unexpected index of the collection")
+	}
+}
@@ -0,0 +1,633 @@
+package xml
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	xmlparser "github.com/tamerh/xml-stream-parser"
+)
+
+type ConstraintKind string
+
+const (
+	ConstraintKey    ConstraintKind = "key"
+	ConstraintKeyRef ConstraintKind = "keyref"
+	ConstraintUnique ConstraintKind = "unique"
+)
+
+type ViolationKind string
+
+const (
+	// two selected elements of a key or unique constraint have the same fields
+	ViolationDuplicate ViolationKind = "duplicate"
+	// a selected element of a key constraint lacks one or more fields
+	ViolationMissingField ViolationKind = "missingField"
+	// a selected element of a keyref constraint refers to no key
+	ViolationMissingKey ViolationKind = "missingKey"
+)
+
+var (
+	ErrConstraintXPath = errors.New("unsupported identity constraint xpath")
+	ErrConstraintRefer = errors.New("keyref refers to an unknown constraint")
+)
+
+// Constraint is an identity constraint (xsd:key, xsd:keyref or xsd:unique) of
+// a schema
+type Constraint struct {
+	Kind     ConstraintKind
+	Name     string
+	Scope    string // name of the element declaring the constraint
+	Refer    string // name of the key or unique constraint of a keyref
+	Selector string
+	Fields   []string
+
+	selector []selectorPath
+	fields   []fieldPath
+	refer    *Constraint
+}
+
+// selectorPath is one of the alternatives ("|") of a selector, e.g.
+// ".//netex:Line"
+type selectorPath struct {
+	descendant bool
+	steps      []string
+}
+
+// fieldPath is a field, e.g. "@version" or "netex:CalendarDate"
+type fieldPath struct {
+	steps []string
+	attr  string
+}
+
+// ConstraintViolation is an element violating an identity constraint
+type ConstraintViolation struct {
+	Kind       ViolationKind
+	Constraint *Constraint
+	Node       Node
+	// field values joined by ";", a missing field is empty
+	Key string
+	// present fields as xpath="value", e.g. @ref="SE:005:Line:1"
+	Descriptor string
+}
+
+// ConstraintSet holds the identity constraints of a schema, compiled once and
+// checked in a single pass over one or more documents
+type ConstraintSet struct {
+	Constraints []*Constraint
+
+	byName map[string]*Constraint
+	// selector alternatives by the name of their last step
+	byStep map[string][]selectorMatch
+}
+
+type selectorMatch struct {
+	constraint *Constraint
+	path       selectorPath
+}
+
+// CompileConstraints compiles the identity constraints declared in the schema
+// at xsdPath, included and imported schemas are not followed
+func CompileConstraints(xsdPath string) (*ConstraintSet, error) {
+	doc, err := NewDocument("xsd", xsdPath)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	root, err := doc.newElement()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ConstraintSet{
+		Constraints: []*Constraint{},
+		byName:      map[string]*Constraint{},
+		byStep:      map[string][]selectorMatch{},
+	}
+	walkElements(root.el, func(el *xmlparser.XMLElement) {
+		if err != nil {
+			return
+		}
+
+		kind := ConstraintKind(localName(el.Name))
+		switch kind {
+		case ConstraintKey, ConstraintKeyRef, ConstraintUnique:
+			var c *Constraint
+			if c, err = compileConstraint(kind, el); err == nil {
+				s.Constraints = append(s.Constraints, c)
+				s.byName[c.Name] = c
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.Constraints {
+		if c.Kind == ConstraintKeyRef {
+			if c.refer = s.byName[c.Refer]; c.refer == nil || c.refer.Kind == ConstraintKeyRef {
+				return nil, fmt.Errorf("%w '%s' (%s)", ErrConstraintRefer, c.Refer, c.Name)
+			}
+		}
+		for _, p := range c.selector {
+			last := p.steps[len(p.steps)-1]
+			s.byStep[last] = append(s.byStep[last], selectorMatch{c, p})
+		}
+	}
+
+	return s, nil
+}
+
+func compileConstraint(kind ConstraintKind, el *xmlparser.XMLElement) (*Constraint, error) {
+	c := &Constraint{
+		Kind:   kind,
+		Name:   el.Attrs["name"],
+		Refer:  localName(el.Attrs["refer"]),
+		Fields: []string{},
+		fields: []fieldPath{},
+	}
+	if parent := el.Parent(); parent != nil {
+		c.Scope = parent.Attrs["name"]
+	}
+
+	for _, child := range el.Children() {
+		xpath := child.Attrs["xpath"]
+		switch localName(child.Name) {
+		case "selector":
+			paths, err := compileSelector(xpath)
+			if err != nil {
+				return nil, fmt.Errorf("%w '%s' (%s)", err, xpath, c.Name)
+			}
+			c.Selector = xpath
+			c.selector = paths
+		case "field":
+			field, err := compileField(xpath)
+			if err != nil {
+				return nil, fmt.Errorf("%w '%s' (%s)", err, xpath, c.Name)
+			}
+			c.Fields = append(c.Fields, xpath)
+			c.fields = append(c.fields, field)
+		}
+	}
+	if len(c.selector) == 0 || len(c.fields) == 0 {
+		return nil, fmt.Errorf("%w, missing selector or field (%s)", ErrConstraintXPath, c.Name)
+	}
+
+	return c, nil
+}
+
+// compileSelector compiles the restricted xpath of an xsd:selector, e.g.
+// ".//netex:Line | netex:Network/netex:Line"
+func compileSelector(xpath string) ([]selectorPath, error) {
+	paths := []selectorPath{}
+	for _, alt := range strings.Split(xpath, "|") {
+		p := selectorPath{}
+		alt = strings.TrimSpace(alt)
+		if strings.HasPrefix(alt, ".//") {
+			p.descendant = true
+			alt = alt[3:]
+		}
+
+		steps, err := compileSteps(alt)
+		if err != nil {
+			return nil, err
+		} else if len(steps) == 0 {
+			return nil, ErrConstraintXPath
+		}
+		p.steps = steps
+		paths = append(paths, p)
+	}
+
+	return paths, nil
+}
+
+// compileField compiles the restricted xpath of an xsd:field, e.g. "@ref",
+// "././@version" or "netex:CalendarDate"
+func compileField(xpath string) (fieldPath, error) {
+	f := fieldPath{}
+	xpath = strings.TrimSpace(xpath)
+	if i := strings.LastIndex(xpath, "@"); i >= 0 {
+		if i > 0 && xpath[i-1] != '/' {
+			return f, ErrConstraintXPath
+		}
+		f.attr = localName(xpath[i+1:])
+		xpath = strings.TrimSuffix(xpath[:i], "/")
+	}
+
+	steps, err := compileSteps(xpath)
+	if err != nil {
+		return f, err
+	}
+	f.steps = steps
+
+	return f, nil
+}
+
+// compileSteps returns the local names of the child steps of a path, "."
+// steps are dropped
+func compileSteps(path string) ([]string, error) {
+	steps := []string{}
+	if path == "" {
+		return steps, nil
+	}
+
+	for _, step := range strings.Split(path, "/") {
+		step = strings.TrimSpace(step)
+		switch {
+		case step == ".":
+			continue
+		case step == "" || strings.ContainsAny(step, "[]()@.") || strings.Contains(step, "::"):
+			return nil, ErrConstraintXPath
+		}
+		steps = append(steps, localName(step))
+	}
+
+	return steps, nil
+}
+
+// constraintEntry is an element selected by a constraint
+type constraintEntry struct {
+	el       *xmlparser.XMLElement
+	document string
+	values   []string
+	present  []bool
+}
+
+// constraintTable holds the elements selected by a constraint within one
+// instance of its scope element, scope is nil for the merged root elements of
+// the checked nodes
+type constraintTable struct {
+	scope   *xmlparser.XMLElement
+	entries []constraintEntry
+}
+
+// Check checks the constraints of the given kinds (all if none) in a single
+// pass over nodes (documents or elements). Every instance of the element
+// declaring a constraint has a table of its own, as in the schema
+// specification, except for the root elements of the nodes which are treated
+// as one: over a collection a keyref declared on PublicationDelivery may refer
+// to a key in any of its documents.
+//
+// Key references follow the conventions of NeTEx rather than the schema
+// specification: an element referring by versionRef is not checked and a
+// missing field (e.g. @version) matches any value of the key.
+func (s *ConstraintSet) Check(nodes []Node, kinds ...ConstraintKind) ([]ConstraintViolation, error) {
+	active := map[*Constraint]bool{}
+	for _, c := range s.Constraints {
+		if len(kinds) == 0 || containsKind(kinds, c.Kind) {
+			active[c] = true
+			if c.refer != nil {
+				active[c.refer] = true
+			}
+		}
+	}
+
+	type tableKey struct {
+		c     *Constraint
+		scope *xmlparser.XMLElement
+	}
+	roots := map[*xmlparser.XMLElement]bool{}
+	tables := map[*Constraint][]*constraintTable{}
+	byKey := map[tableKey]*constraintTable{}
+	table := func(c *Constraint, scope *xmlparser.XMLElement) *constraintTable {
+		if roots[scope] {
+			scope = nil
+		}
+		t, ok := byKey[tableKey{c, scope}]
+		if !ok {
+			t = &constraintTable{scope: scope}
+			byKey[tableKey{c, scope}] = t
+			tables[c] = append(tables[c], t)
+		}
+
+		return t
+	}
+
+	for _, n := range nodes {
+		root, err := rootElement(n)
+		if err != nil {
+			return nil, err
+		}
+		roots[root] = true
+
+		document := n.DocumentName()
+		walkElements(root, func(el *xmlparser.XMLElement) {
+			for _, m := range s.byStep[localName(el.Name)] {
+				if !active[m.constraint] {
+					continue
+				}
+				scopes := m.path.scopes(el, m.constraint.Scope)
+				if len(scopes) == 0 {
+					continue
+				}
+				// a keyref is checked against the keys of its nearest scope,
+				// which holds the keys of any nested scope as well
+				if m.constraint.Kind == ConstraintKeyRef {
+					scopes = scopes[:1]
+				}
+				e := newConstraintEntry(el, document, m.constraint)
+				for _, scope := range scopes {
+					t := table(m.constraint, scope)
+					t.entries = append(t.entries, e)
+				}
+			}
+		})
+	}
+
+	type reported struct {
+		c    *Constraint
+		el   *xmlparser.XMLElement
+		kind ViolationKind
+	}
+	res := []ConstraintViolation{}
+	seen := map[reported]bool{}
+	for _, c := range s.Constraints {
+		if len(kinds) != 0 && !containsKind(kinds, c.Kind) {
+			continue
+		}
+
+		for _, t := range tables[c] {
+			var violations []ConstraintViolation
+			switch c.Kind {
+			case ConstraintKey, ConstraintUnique:
+				violations = checkUnique(c, t.entries)
+			case ConstraintKeyRef:
+				keys := []constraintEntry{}
+				for _, kt := range tables[c.refer] {
+					if withinScope(kt.scope, t.scope, roots) {
+						keys = append(keys, kt.entries...)
+					}
+				}
+				violations = checkKeyRef(c, t.entries, keys)
+			}
+
+			// an element in nested scopes is in the table of each of them,
+			// but is reported once
+			for _, v := range violations {
+				r := reported{c, v.Node.(*Element).el, v.Kind}
+				if !seen[r] {
+					seen[r] = true
+					res = append(res, v)
+				}
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// withinScope reports whether the scope instance el is scope or one of its
+// descendants, a nil scope being the merged root elements
+func withinScope(el, scope *xmlparser.XMLElement, roots map[*xmlparser.XMLElement]bool) bool {
+	if el == nil {
+		return scope == nil
+	}
+	for ; el != nil; el = el.Parent() {
+		if el == scope || (scope == nil && roots[el]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkUnique(c *Constraint, entries []constraintEntry) []ConstraintViolation {
+	res := []ConstraintViolation{}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		if !e.complete() {
+			// fields of a key are required, a unique constraint only applies
+			// to elements having every field
+			if c.Kind == ConstraintKey {
+				res = append(res, e.violation(ViolationMissingField, c))
+			}
+			continue
+		}
+
+		k, _ := e.project("")
+		if seen[k] {
+			res = append(res, e.violation(ViolationDuplicate, c))
+		}
+		seen[k] = true
+	}
+
+	return res
+}
+
+func checkKeyRef(c *Constraint, refs, keys []constraintEntry) []ConstraintViolation {
+	res := []ConstraintViolation{}
+	// keys projected on the fields present in a reference, by mask of the
+	// present fields
+	projections := map[string]map[string]bool{}
+	for _, ref := range refs {
+		if _, ok := ref.el.Attrs["versionRef"]; ok {
+			continue
+		}
+
+		mask := ref.mask()
+		if !strings.Contains(mask, "1") {
+			continue
+		}
+		keySet, ok := projections[mask]
+		if !ok {
+			keySet = map[string]bool{}
+			for _, key := range keys {
+				if k, ok := key.project(mask); ok {
+					keySet[k] = true
+				}
+			}
+			projections[mask] = keySet
+		}
+
+		if k, _ := ref.project(mask); !keySet[k] {
+			res = append(res, ref.violation(ViolationMissingKey, c))
+		}
+	}
+
+	return res
+}
+
+func newConstraintEntry(el *xmlparser.XMLElement, document string, c *Constraint) constraintEntry {
+	e := constraintEntry{
+		el:       el,
+		document: document,
+		values:   make([]string, len(c.fields)),
+		present:  make([]bool, len(c.fields)),
+	}
+	for i, f := range c.fields {
+		e.values[i], e.present[i] = f.value(el)
+	}
+
+	return e
+}
+
+func (e constraintEntry) complete() bool {
+	for _, ok := range e.present {
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// key returns the field values for display, joined by ";"
+func (e constraintEntry) key() string { return strings.Join(e.values, ";") }
+
+// mask returns the fields present as a string of "1" (present) and "0"
+func (e constraintEntry) mask() string {
+	var sb strings.Builder
+	for _, ok := range e.present {
+		if ok {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+
+	return sb.String()
+}
+
+// project returns the values of the fields in mask (every field if mask is
+// empty) encoded as a map key, ok is false if any of them is missing. Every
+// value is prefixed by its length, so distinct values never share a key
+// whatever they contain.
+func (e constraintEntry) project(mask string) (string, bool) {
+	var sb strings.Builder
+	for i, ok := range e.present {
+		if mask != "" && (i >= len(mask) || mask[i] != '1') {
+			continue
+		} else if !ok {
+			return "", false
+		}
+		fmt.Fprintf(&sb, "%d:%s", len(e.values[i]), e.values[i])
+	}
+
+	return sb.String(), true
+}
+
+func (e constraintEntry) violation(kind ViolationKind, c *Constraint) ConstraintViolation {
+	descriptor := []string{}
+	for i, ok := range e.present {
+		if ok {
+			descriptor = append(descriptor, fmt.Sprintf(`%s="%s"`, c.Fields[i], e.values[i]))
+		}
+	}
+
+	return ConstraintViolation{
+		Kind:       kind,
+		Constraint: c,
+		Node: &Element{
+			el:       e.el,
+			document: e.document,
+		},
+		Key:        e.key(),
+		Descriptor: strings.Join(descriptor, ", "),
+	}
+}
+
+// scopes returns the elements named scope el is selected from by the path,
+// nearest first, none if el is not selected
+func (p selectorPath) scopes(el *xmlparser.XMLElement, scope string) []*xmlparser.XMLElement {
+	for i := len(p.steps) - 1; i >= 0; i-- {
+		if el == nil || !stepMatches(p.steps[i], el) {
+			return nil
+		}
+		el = el.Parent()
+	}
+
+	if !p.descendant {
+		if el != nil && localName(el.Name) == scope {
+			return []*xmlparser.XMLElement{el}
+		}
+		return nil
+	}
+
+	scopes := []*xmlparser.XMLElement{}
+	for ; el != nil; el = el.Parent() {
+		if localName(el.Name) == scope {
+			scopes = append(scopes, el)
+		}
+	}
+
+	return scopes
+}
+
+// value returns the value of the field of el, ok is false if it is missing
+func (f fieldPath) value(el *xmlparser.XMLElement) (string, bool) {
+	for _, step := range f.steps {
+		var next *xmlparser.XMLElement
+		for _, child := range el.Children() {
+			if stepMatches(step, child) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return "", false
+		}
+		el = next
+	}
+
+	if f.attr != "" {
+		v, ok := el.Attrs[f.attr]
+		return v, ok
+	}
+
+	return strings.TrimSpace(el.InnerText), true
+}
+
+func stepMatches(step string, el *xmlparser.XMLElement) bool {
+	return step == "*" || step == localName(el.Name)
+}
+
+func containsKind(kinds []ConstraintKind, kind ConstraintKind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// localName strips the namespace prefix of a name, e.g. "netex:Line"
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
+
+// walkElements calls fn for el and its descendants in document order
+func walkElements(el *xmlparser.XMLElement, fn func(el *xmlparser.XMLElement)) {
+	stack := []*xmlparser.XMLElement{el}
+	for len(stack) > 0 {
+		el := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		fn(el)
+
+		childs := el.Children()
+		for i := len(childs) - 1; i >= 0; i-- {
+			stack = append(stack, childs[i])
+		}
+	}
+}
+
+// rootElement returns the parsed element of a document or element node
+func rootElement(n Node) (*xmlparser.XMLElement, error) {
+	switch v := n.(type) {
+	case *Document:
+		el, err := v.newElement()
+		if err != nil {
+			return nil, err
+		}
+		return el.el, nil
+	case *Element:
+		return v.el, nil
+	}
+
+	return nil, fmt.Errorf("unsupported node type %T", n)
+}
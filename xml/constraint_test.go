@@ -0,0 +1,204 @@
+package xml
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testSchema declares a unique Operator on Delivery, and a unique Stop, a
+// compound key of Line by id and version and a keyref to it on every Frame
+const testSchema = `<?xml version="1.0"?>
+<xsd:schema xmlns:xsd="http://www.w3.org/2001/XMLSchema">
+  <xsd:element name="Delivery">
+    <xsd:unique name="Operator_UniqueBy_Id">
+      <xsd:selector xpath=".//Operator"/>
+      <xsd:field xpath="@id"/>
+    </xsd:unique>
+  </xsd:element>
+  <xsd:element name="Frame">
+    <xsd:unique name="Stop_UniqueBy_Id">
+      <xsd:selector xpath=".//Stop"/>
+      <xsd:field xpath="@id"/>
+    </xsd:unique>
+    <xsd:key name="Line_KeyBy_Id_Version">
+      <xsd:selector xpath=".//Line"/>
+      <xsd:field xpath="@id"/>
+      <xsd:field xpath="@version"/>
+    </xsd:key>
+    <xsd:keyref name="LineRef_Frame" refer="Line_KeyBy_Id_Version">
+      <xsd:selector xpath=".//LineRef"/>
+      <xsd:field xpath="@ref"/>
+      <xsd:field xpath="@version"/>
+    </xsd:keyref>
+  </xsd:element>
+</xsd:schema>`
+
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestConstraintSetCheck(t *testing.T) {
+	set, err := CompileConstraints(writeTestFile(t, "schema.xsd", testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "unique per frame",
+			doc: `<Delivery>
+  <Frame><Stop id="1"/></Frame>
+  <Frame><Stop id="1"/></Frame>
+</Delivery>`,
+			want: []string{},
+		},
+		{
+			name: "duplicate within a frame",
+			doc: `<Delivery>
+  <Frame><Stop id="1"/><Stop id="1"/></Frame>
+</Delivery>`,
+			want: []string{"duplicate Stop_UniqueBy_Id 1"},
+		},
+		{
+			name: "nested frames share the outer table",
+			doc: `<Delivery>
+  <Frame>
+    <Stop id="1"/>
+    <Frame><Stop id="1"/></Frame>
+  </Frame>
+</Delivery>`,
+			want: []string{"duplicate Stop_UniqueBy_Id 1"},
+		},
+		{
+			name: "duplicate in nested frame reported once",
+			doc: `<Delivery>
+  <Frame>
+    <Frame><Stop id="1"/><Stop id="1"/></Frame>
+  </Frame>
+</Delivery>`,
+			want: []string{"duplicate Stop_UniqueBy_Id 1"},
+		},
+		{
+			name: "compound keys don't collide on the separator",
+			doc: `<Delivery><Frame>
+  <Line id="a;b" version="c"/>
+  <Line id="a" version="b;c"/>
+</Frame></Delivery>`,
+			want: []string{},
+		},
+		{
+			name: "duplicate compound key",
+			doc: `<Delivery><Frame>
+  <Line id="a" version="1"/>
+  <Line id="a" version="1"/>
+</Frame></Delivery>`,
+			want: []string{"duplicate Line_KeyBy_Id_Version a;1"},
+		},
+		{
+			name: "missing key field",
+			doc: `<Delivery><Frame>
+  <Line id="a"/>
+</Frame></Delivery>`,
+			want: []string{"missingField Line_KeyBy_Id_Version a;"},
+		},
+		{
+			name: "keyref with missing field matches any version",
+			doc: `<Delivery>
+  <Frame><Line id="a" version="1"/><LineRef ref="a"/><LineRef ref="a" version="1"/></Frame>
+</Delivery>`,
+			want: []string{},
+		},
+		{
+			name: "keyref to unknown key",
+			doc: `<Delivery>
+  <Frame><Line id="a" version="1"/><LineRef ref="a" version="2"/><LineRef ref="a;1"/></Frame>
+</Delivery>`,
+			want: []string{"missingKey LineRef_Frame a;1;", "missingKey LineRef_Frame a;2"},
+		},
+		{
+			name: "keyref in frame refers to keys of the frame only",
+			doc: `<Delivery>
+  <Frame><Line id="a" version="1"/></Frame>
+  <Frame><LineRef ref="a" version="1"/></Frame>
+</Delivery>`,
+			want: []string{"missingKey LineRef_Frame a;1"},
+		},
+		{
+			name: "keyref in frame refers to keys of nested frames",
+			doc: `<Delivery>
+  <Frame>
+    <Frame><Line id="a" version="1"/></Frame>
+    <LineRef ref="a" version="1"/>
+  </Frame>
+</Delivery>`,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := NewDocument("test.xml", writeTestFile(t, "test.xml", tt.doc))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer doc.Close()
+
+			res, err := set.Check([]Node{doc})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, v := range res {
+				got = append(got, string(v.Kind)+" "+v.Constraint.Name+" "+v.Key)
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstraintSetCheckCollection(t *testing.T) {
+	set, err := CompileConstraints(writeTestFile(t, "schema.xsd", testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes := []Node{}
+	for _, content := range []string{
+		`<Delivery><Operator id="a"/><Frame><Stop id="1"/></Frame></Delivery>`,
+		`<Delivery><Operator id="a"/><Frame><Stop id="1"/></Frame></Delivery>`,
+	} {
+		doc, err := NewDocument("test.xml", writeTestFile(t, "test.xml", content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer doc.Close()
+		nodes = append(nodes, doc)
+	}
+
+	// the root elements are one scope, so the operator is duplicated across
+	// the documents while the stops are in frames of their own
+	res, err := set.Check(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0].Kind != ViolationDuplicate || res[0].Constraint.Name != "Operator_UniqueBy_Id" {
+		t.Errorf("expected a single duplicate operator, got %+v", res)
+	}
+}
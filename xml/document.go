@@ -2,8 +2,12 @@ package xml
 
 import (
 	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
 	"math"
 	"os"
+	"sort"
 	"sync"
 
 	"github.com/concreteit/greenlight/internal"
@@ -12,8 +16,12 @@ import (
 
 type Document struct {
 	sync.RWMutex
-	el   *Element
-	file *os.File
+	el       *Element
+	file     *os.File
+	checksum string
+	indexes  indexCache
+	// called once the document is closed, see onClose
+	closeHooks []func()
 
 	Name     string
 	FilePath string
@@ -30,12 +38,49 @@ func NewDocument(name, filePath string) (*Document, error) {
 
 func (d *Document) Close() {
 	d.Lock()
-	defer d.Unlock()
-
 	d.el = nil
+	d.indexes.reset()
 	if d.file != nil {
 		d.file.Close()
 	}
+	hooks := d.closeHooks
+	d.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// onClose registers fn to be called every time the document is closed, e.g. to
+// drop the nodes of the document held by the index of a collection
+func (d *Document) onClose(fn func()) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.closeHooks = append(d.closeHooks, fn)
+}
+
+// Checksum returns the sha256 checksum of the document's content
+func (d *Document) Checksum() (string, error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.checksum == "" {
+		f, err := os.Open(d.FilePath)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+
+		d.checksum = fmt.Sprintf("%x", h.Sum(nil))
+	}
+
+	return d.checksum, nil
 }
 
 func (d *Document) newElement() (*Element, error) {
@@ -58,6 +103,7 @@ func (d *Document) newElement() (*Element, error) {
 		}
 
 		d.el = NewElement(el)
+		d.el.document = d.Name
 	}
 
 	return d.el, nil
@@ -85,6 +131,37 @@ func (d *Document) Find(q string) internal.Result { return internal.NewResult(d.
 
 func (d *Document) First(q string) internal.Result { return internal.NewResult(d.first(q)) }
 
+// Index returns the nodes matching q (e.g. "//LineRef") by the value of key
+// relative to each node (e.g. "@ref"). The index is built on first use and
+// shared by every rule running on the document.
+func (d *Document) Index(q, key string) internal.Result { return internal.NewResult(d.index(q, key)) }
+
+func (d *Document) index(q, key string) (*Index, error) {
+	return d.indexes.get(q, key, func() (*Index, error) {
+		idx := newIndex()
+		nodes, err := d.find(q)
+		if err == ErrNodeNotFound {
+			return idx, nil
+		} else if err != nil {
+			return nil, err
+		}
+		idx.add(nodes, key)
+
+		return idx, nil
+	})
+}
+
+// ById returns the first element of type t (e.g. "ServiceJourney") with the
+// given @id
+func (d *Document) ById(t, id string) internal.Result {
+	idx, err := d.index(byIdQuery(t), "@id")
+	if err != nil {
+		return internal.NewResult(nil, err)
+	}
+
+	return idx.First(id)
+}
+
 func (d *Document) Line() int {
 	el, err := d.newElement()
 	if err != nil {
@@ -94,6 +171,50 @@ func (d *Document) Line() int {
 	return el.Line()
 }
 
+func (d *Document) Column() int {
+	el, err := d.newElement()
+	if err != nil {
+		return 0
+	}
+
+	return el.Column()
+}
+
+func (d *Document) Path() string {
+	el, err := d.newElement()
+	if err != nil {
+		return ""
+	}
+
+	return el.Path()
+}
+
+// ElementAt returns the innermost element spanning the given line
+func (d *Document) ElementAt(line int) (Node, error) {
+	el, err := d.newElement()
+	if err != nil {
+		return nil, err
+	}
+
+	e := el.el
+	if line < e.Line || line > e.EndLine {
+		return nil, ErrNodeNotFound
+	}
+	for {
+		childs := e.Children()
+		i := sort.Search(len(childs), func(i int) bool { return childs[i].EndLine >= line })
+		if i == len(childs) || childs[i].Line > line {
+			break
+		}
+		e = childs[i]
+	}
+
+	return &Element{
+		el:       e,
+		document: d.Name,
+	}, nil
+}
+
 func (d *Document) Parent() internal.Result {
 	_, err := d.newElement()
 	if err != nil {
@@ -121,6 +242,8 @@ func (d *Document) TextAt(q string) internal.Result {
 	return el.TextAt(q)
 }
 
+func (d *Document) DocumentName() string { return d.Name }
+
 func (d *Document) Attr(k string) internal.Result {
 	el, err := d.newElement()
 	if err != nil {
@@ -8,7 +8,8 @@ import (
 )
 
 type Element struct {
-	el *xmlparser.XMLElement
+	el       *xmlparser.XMLElement
+	document string
 }
 
 func NewElement(el *xmlparser.XMLElement) *Element {
@@ -33,7 +34,10 @@ func (o *Element) find(q string) ([]Node, error) {
 				continue
 			}
 		}
-		eles = append(eles, NewElement(v))
+		eles = append(eles, &Element{
+			el:       v,
+			document: o.document,
+		})
 	}
 
 	return eles, nil
@@ -56,6 +60,11 @@ func (o *Element) First(q string) internal.Result { return internal.NewResult(o.
 
 func (o *Element) Line() int { return o.el.Line }
 
+func (o *Element) Column() int { return o.el.Column }
+
+// Path returns the absolute XPath of the element
+func (o *Element) Path() string { return o.el.Path() }
+
 func (o *Element) Parent() internal.Result { return internal.NewResult(o.first("..")) }
 
 func (o *Element) Text() string { return o.el.InnerText }
@@ -78,6 +87,9 @@ func (o *Element) TextAt(q string) internal.Result {
 	return internal.NewResult(el.Text(), nil)
 }
 
+// DocumentName returns the name of the document the element belongs to
+func (o *Element) DocumentName() string { return o.document }
+
 func (o *Element) Attr(k string) internal.Result {
 	v := o.el.Attrs[k]
 	if v == "" {
@@ -0,0 +1,252 @@
+package xml
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/concreteit/greenlight/internal"
+)
+
+// Index maps the value of a key (e.g. "@ref") to the nodes matching a query
+// (e.g. "//LineRef"), in document order
+type Index struct {
+	data map[string][]Node
+
+	// indexes of the nodes of a collection in order, set instead of data
+	parts []*Index
+}
+
+func newIndex() *Index {
+	return &Index{
+		data: map[string][]Node{},
+	}
+}
+
+func (i *Index) add(nodes []Node, key string) {
+	for _, n := range nodes {
+		if k, ok := n.TextAt(key).Get().(string); ok {
+			k = strings.TrimSpace(k)
+			i.data[k] = append(i.data[k], n)
+		}
+	}
+}
+
+// Get returns the nodes with the key k, an empty slice if there are none
+func (i *Index) Get(k string) []Node {
+	if i.parts == nil {
+		if nodes, ok := i.data[k]; ok {
+			return nodes
+		}
+		return []Node{}
+	}
+
+	nodes := []Node{}
+	for _, p := range i.parts {
+		nodes = append(nodes, p.data[k]...)
+	}
+
+	return nodes
+}
+
+// First returns the first node with the key k
+func (i *Index) First(k string) internal.Result {
+	if i.parts == nil {
+		if nodes := i.data[k]; len(nodes) > 0 {
+			return internal.NewResult(nodes[0], nil)
+		}
+	}
+	for _, p := range i.parts {
+		if nodes := p.data[k]; len(nodes) > 0 {
+			return internal.NewResult(nodes[0], nil)
+		}
+	}
+
+	return internal.NewResult(nil, ErrNodeNotFound)
+}
+
+func (i *Index) Has(k string) bool {
+	if i.parts == nil {
+		_, ok := i.data[k]
+		return ok
+	}
+	for _, p := range i.parts {
+		if _, ok := p.data[k]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys returns the keys of the index in sorted order
+func (i *Index) Keys() []string {
+	keys := make([]string, 0, len(i.data))
+	if i.parts == nil {
+		for k := range i.data {
+			keys = append(keys, k)
+		}
+	} else {
+		seen := map[string]bool{}
+		for _, p := range i.parts {
+			for k := range p.data {
+				if !seen[k] {
+					seen[k] = true
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (i *Index) Size() int {
+	if i.parts == nil {
+		return len(i.data)
+	}
+
+	return len(i.Keys())
+}
+
+// indexCache holds the indexes of a document, each built once on first use
+type indexCache struct {
+	mu   sync.Mutex
+	data map[string]*indexEntry
+}
+
+type indexEntry struct {
+	once  sync.Once
+	index *Index
+	err   error
+}
+
+// get returns the index of q by key, building it with build on first use.
+// Concurrent callers of the same index wait for a single build.
+func (c *indexCache) get(q, key string, build func() (*Index, error)) (*Index, error) {
+	c.mu.Lock()
+	if c.data == nil {
+		c.data = map[string]*indexEntry{}
+	}
+	k := q + "\x00" + key
+	e, ok := c.data[k]
+	if !ok {
+		e = &indexEntry{}
+		c.data[k] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() { e.index, e.err = build() })
+
+	return e.index, e.err
+}
+
+func (c *indexCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data = nil
+}
+
+// collectionIndexes holds the indexes of a collection, made of the index of
+// each node of the collection. The part of a document is dropped once the
+// document is closed and built again on next use, the others are kept.
+type collectionIndexes struct {
+	mu   sync.Mutex
+	data map[string]*collectionIndex
+	// incremented every time parts are dropped
+	gen int
+}
+
+type collectionIndex struct {
+	// by node of the collection, nil until built or once dropped
+	parts []*Index
+	// nil if any part is missing
+	index *Index
+}
+
+// get returns the index of q by key over nodes, building the missing parts
+func (c *collectionIndexes) get(q, key string, nodes []Node) (*Index, error) {
+	c.mu.Lock()
+	if c.data == nil {
+		c.data = map[string]*collectionIndex{}
+	}
+	k := q + "\x00" + key
+	e, ok := c.data[k]
+	if !ok || len(e.parts) != len(nodes) {
+		e = &collectionIndex{parts: make([]*Index, len(nodes))}
+		c.data[k] = e
+	}
+	if e.index != nil {
+		c.mu.Unlock()
+		return e.index, nil
+	}
+	parts := append([]*Index{}, e.parts...)
+	gen := c.gen
+	c.mu.Unlock()
+
+	for i, part := range parts {
+		if part != nil {
+			continue
+		}
+		var err error
+		if parts[i], err = nodeIndex(nodes[i], q, key); err != nil {
+			return nil, err
+		}
+	}
+	idx := &Index{parts: parts}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// parts built from a document closed meanwhile must not be kept
+	if c.gen == gen && c.data[k] == e {
+		e.parts = parts
+		e.index = idx
+	}
+
+	return idx, nil
+}
+
+// drop drops the parts of the i-th node of the collection
+func (c *collectionIndexes) drop(i int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.data {
+		if i < len(e.parts) {
+			e.parts[i] = nil
+			e.index = nil
+		}
+	}
+	c.gen++
+}
+
+func (c *collectionIndexes) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.data = nil
+	c.gen++
+}
+
+// nodeIndex returns the index of q by key of a single node, the shared index
+// of a document
+func nodeIndex(node Node, q, key string) (*Index, error) {
+	if doc, ok := node.(*Document); ok {
+		return doc.index(q, key)
+	}
+
+	idx := newIndex()
+	nodes, err := node.find(q)
+	if err != nil && err != ErrNodeNotFound {
+		return nil, err
+	}
+	idx.add(nodes, key)
+
+	return idx, nil
+}
+
+// byIdQuery returns the query of the elements of type t, e.g. "ServiceJourney"
+func byIdQuery(t string) string { return "//" + t }
@@ -1,3 +1,4 @@
+#include <stdlib.h>
 #include <string.h>
 #include <libxml/parser.h>
 #include <libxml/parserInternals.h>
@@ -7,19 +8,34 @@
 
 static void validationErrorFunc(void* ctx, xmlError* error) {
   validationResult* res = (validationResult*) ctx;
-  if (res->errorCount >= MAX_VALIDATION_ERRORS_SIZE) {
+  res->totalCount++;
+  if (res->maxErrors > 0 && res->errorCount >= res->maxErrors) {
     return;
   }
+  if (res->errorCount >= res->errorCap) {
+    int cap = res->errorCap > 0 ? res->errorCap * 2 : 16;
+    if (res->maxErrors > 0 && cap > res->maxErrors) {
+      cap = res->maxErrors;
+    }
+    errMessage **errors = realloc(res->errors, cap * sizeof(errMessage*));
+    if (errors == NULL) {
+      return;
+    }
+    res->errors = errors;
+    res->errorCap = cap;
+  }
 
   char* msgStr = NULL;
   if (error->message != NULL) {
-    msgStr = malloc(strlen(error->message));
+    msgStr = malloc(strlen(error->message) + 1);
     strcpy(msgStr, error->message);
   }
 
   int i = res->errorCount++;
   errMessage *msg = malloc(sizeof(errMessage));
   msg->line = error->line;
+  msg->col = error->int2;
+  msg->extra = error->code;
   msg->level = error->level;
   msg->message = msgStr;
   res->errors[i] = msg;
@@ -42,30 +58,31 @@ xmlSchemaPtr schemaParse(char* schemaPath) {
   return schema;
 }
 
-static validationResult* newValidationResult() {
-	int i;
-	validationResult *res;
-	res = (validationResult*) malloc(sizeof(validationResult));
-	for (i = 0; i < MAX_VALIDATION_ERRORS_SIZE; i++) {
-		res->errors[i] = NULL;
-	}
+static validationResult* newValidationResult(int maxErrors) {
+  validationResult *res;
+  res = (validationResult*) malloc(sizeof(validationResult));
+  res->errors = NULL;
   res->errorCount = 0;
+  res->errorCap = 0;
+  res->maxErrors = maxErrors;
+  res->totalCount = 0;
+  res->errorCode = 0;
   return res;
 }
 
 void freeValidationResult(validationResult* res) {
   int i;
-  for (i = 0; i < MAX_VALIDATION_ERRORS_SIZE; i++) {
-		if (res->errors[i] != NULL) {
-      free(res->errors[i]);
-		}
-	}
+  for (i = 0; i < res->errorCount; i++) {
+    free(res->errors[i]->message);
+    free(res->errors[i]);
+  }
+  free(res->errors);
   free(res);
 }
 
-validationResult* validateStream(xmlSchemaPtr schema, char *xmlPath) {
+validationResult* validateStream(xmlSchemaPtr schema, char *xmlPath, int maxErrors) {
   const char *user_data = "user_data";
-  validationResult* res = newValidationResult();
+  validationResult* res = newValidationResult(maxErrors);
   xmlSAXHandler h = newSAXHandler();
   xmlParserInputBufferPtr buf = xmlParserInputBufferCreateFilename(xmlPath, XML_CHAR_ENCODING_NONE);
   if (buf == NULL) {
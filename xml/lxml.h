@@ -3,7 +3,6 @@
 #include <libxml/SAX.h>
 #include <libxml/xmlschemas.h>
 
-#define MAX_VALIDATION_ERRORS_SIZE 1000
 #define ERR_VALIDATION_PARSER -1
 #define ERR_VALIDATION_CONTEXT -2
 #define ERR_VALIDATION_STREAM -3
@@ -12,7 +11,7 @@ typedef struct errMessage {
   int line;
   int level;
   char* message;
-  int extra;
+  int extra; // xmlParserErrors code
   int col;
   char* extra1;
   char* extra2;
@@ -20,8 +19,11 @@ typedef struct errMessage {
 } errMessage;
 
 typedef struct validationResult {
-  errMessage *errors[MAX_VALIDATION_ERRORS_SIZE];
-  int errorCount;
+  errMessage **errors;
+  int errorCount; // number of errors stored
+  int errorCap; // size of errors
+  int maxErrors; // number of errors to store at most, all of them if zero or less
+  int totalCount; // number of errors reported, including the ones not stored
   int errorCode;
 } validationResult;
 
@@ -29,7 +31,8 @@ typedef struct validationResult {
 xmlSchemaPtr schemaParse(char* schemaPath);
 
 // Do a schemas validation of the given resource, it will use the SAX streamable validation internally.
-validationResult* validateStream(xmlSchemaPtr schema, char* xmlPath);
+// At most maxErrors errors are stored, all of them if zero or less, the others are only counted.
+validationResult* validateStream(xmlSchemaPtr schema, char* xmlPath, int maxErrors);
 
 // Free validation result
 void freeValidationResult(validationResult* res);
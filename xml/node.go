@@ -18,8 +18,11 @@ type Node interface {
 	Find(q string) internal.Result
 	First(q string) internal.Result
 	Line() int
+	Column() int
+	Path() string
 	Parent() internal.Result
 	Text() string
 	TextAt(q string) internal.Result
 	Attr(k string) internal.Result
+	DocumentName() string
 }
@@ -7,6 +7,15 @@ package xml
 import "C"
 import (
 	"fmt"
+	"unsafe"
+)
+
+// error levels as reported by libxml (xmlErrorLevel)
+const (
+	ErrorLevelNone    = 0
+	ErrorLevelWarning = 1
+	ErrorLevelError   = 2
+	ErrorLevelFatal   = 3
 )
 
 var (
@@ -29,8 +38,11 @@ func NewSchema(xsdPath string) (*Schema, error) {
 	}, nil
 }
 
-func (s *Schema) Validate(filePath string) (*ValidationResult, error) {
-	cres := C.validateStream(s.ptr, C.CString(filePath))
+// Validate validates the document at filePath against the schema, storing at
+// most maxErrors errors (all of them if zero or less), the others are only
+// counted
+func (s *Schema) Validate(filePath string, maxErrors int) (*ValidationResult, error) {
+	cres := C.validateStream(s.ptr, C.CString(filePath), C.int(maxErrors))
 	if cres == nil {
 		return nil, ErrSchemaValidation
 	}
@@ -44,12 +56,15 @@ func (s *Schema) Validate(filePath string) (*ValidationResult, error) {
 
 	res := &ValidationResult{
 		Valid:  false,
+		Total:  int(cres.totalCount),
 		Errors: []ValidationError{},
 	}
-	for i := 0; i < int(cres.errorCount); i++ {
-		errMsg := cres.errors[i]
+	errs := unsafe.Slice(cres.errors, int(cres.errorCount))
+	for _, errMsg := range errs {
 		res.Errors = append(res.Errors, ValidationError{
 			Line:    int(errMsg.line),
+			Column:  int(errMsg.col),
+			Code:    int(errMsg.extra),
 			Level:   int(errMsg.level),
 			Message: C.GoString(errMsg.message),
 		})
@@ -61,12 +76,16 @@ func (s *Schema) Validate(filePath string) (*ValidationResult, error) {
 func (s *Schema) Free() { C.xmlSchemaFree(s.ptr) }
 
 type ValidationResult struct {
-	Valid  bool
+	Valid bool
+	// number of errors found, Errors holds at most the maxErrors of Validate
+	Total  int
 	Errors []ValidationError
 }
 
 type ValidationError struct {
 	Line    int
+	Column  int
 	Level   int
+	Code    int // libxml error code (xmlParserErrors)
 	Message string
 }
@@ -0,0 +1,51 @@
+package xml
+
+import (
+	"strings"
+	"testing"
+)
+
+const linesSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="Lines">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="Line" type="xs:int" maxOccurs="unbounded"/>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+</xs:schema>`
+
+func TestSchemaValidateMaxErrors(t *testing.T) {
+	schema, err := NewSchema(writeTestFile(t, "test.xsd", linesSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer schema.Free()
+
+	doc := writeTestFile(t, "test.xml", "<Lines>"+strings.Repeat("<Line>x</Line>", 40)+"</Lines>")
+	for _, tc := range []struct {
+		maxErrors int
+		want      int
+	}{
+		{0, 40},
+		{5, 5},
+		{100, 40},
+	} {
+		res, err := schema.Validate(doc, tc.maxErrors)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.Valid || res.Total != 40 {
+			t.Errorf("max %d: got valid %t with %d errors, want 40", tc.maxErrors, res.Valid, res.Total)
+		}
+		if len(res.Errors) != tc.want {
+			t.Errorf("max %d: got %d errors stored, want %d", tc.maxErrors, len(res.Errors), tc.want)
+		}
+		for _, e := range res.Errors {
+			if e.Line != 1 || e.Message == "" {
+				t.Errorf("max %d: unexpected error %+v", tc.maxErrors, e)
+			}
+		}
+	}
+}
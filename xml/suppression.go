@@ -0,0 +1,52 @@
+package xml
+
+import (
+	"regexp"
+	"strings"
+)
+
+var suppressionPattern = regexp.MustCompile(`(?s)^greenlight-ignore\s*:\s*(.+)$`)
+
+// Suppression marks the findings of a rule between Line and EndLine as
+// acknowledged by the author of the document, declared with a comment such as
+// <!-- greenlight-ignore: ruleName --> just before the element
+type Suppression struct {
+	Rule    string
+	Line    int
+	EndLine int
+}
+
+// Contains returns whether a finding of rule at line is suppressed
+func (s Suppression) Contains(rule string, line int) bool {
+	return s.Rule == rule && line >= s.Line && line <= s.EndLine
+}
+
+// Suppressions returns every inline suppression declared in the document
+func (d *Document) Suppressions() ([]Suppression, error) {
+	el, err := d.newElement()
+	if err != nil {
+		return nil, err
+	}
+
+	res := []Suppression{}
+	for _, e := range el.el.CommentedElements() {
+		for _, c := range e.Comments {
+			m := suppressionPattern.FindStringSubmatch(c.Text)
+			if m == nil {
+				continue
+			}
+
+			for _, rule := range strings.FieldsFunc(m[1], func(r rune) bool {
+				return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+			}) {
+				res = append(res, Suppression{
+					Rule:    rule,
+					Line:    c.Line,
+					EndLine: e.EndLine,
+				})
+			}
+		}
+	}
+
+	return res, nil
+}
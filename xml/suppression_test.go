@@ -0,0 +1,80 @@
+package xml
+
+import (
+	"testing"
+)
+
+const suppressionTestDocument = `<?xml version="1.0"?>
+<!-- greenlight-ignore: everyLineIsReferenced -->
+<!--
+  greenlight-ignore: everyStopIsReferenced
+-->
+<PublicationDelivery>
+  <!-- greenlight-ignore: frameDefaultsHaveALocaleAndTimeZone, everyLineHasAName -->
+  <Line id="1">
+    <Name>1</Name>
+  </Line>
+  <!-- a plain comment -->
+  <Line id="2"/>
+  <!-- greenlight-ignore:
+       everyScheduledStopPointHasAName,
+       passingTimesIsNotDecreasing -->
+  <Line id="3"/>
+  <!-- greenlight-ignore: everyStopPlaceHasAName -->
+</PublicationDelivery>
+`
+
+func TestSuppressions(t *testing.T) {
+	doc, err := NewDocument("test.xml", writeTestFile(t, "test.xml", suppressionTestDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	got, err := doc.Suppressions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// comments in the prolog belong to the root element, a comment not
+	// followed by an element is dropped
+	want := []Suppression{
+		{Rule: "everyLineIsReferenced", Line: 2, EndLine: 18},
+		{Rule: "everyStopIsReferenced", Line: 3, EndLine: 18},
+		{Rule: "frameDefaultsHaveALocaleAndTimeZone", Line: 7, EndLine: 10},
+		{Rule: "everyLineHasAName", Line: 7, EndLine: 10},
+		{Rule: "everyScheduledStopPointHasAName", Line: 13, EndLine: 16},
+		{Rule: "passingTimesIsNotDecreasing", Line: 13, EndLine: 16},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d suppressions %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("suppression %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestSuppressionContains(t *testing.T) {
+	s := Suppression{Rule: "everyLineHasAName", Line: 7, EndLine: 10}
+
+	tests := []struct {
+		rule string
+		line int
+		want bool
+	}{
+		{"everyLineHasAName", 6, false},
+		{"everyLineHasAName", 7, true},
+		{"everyLineHasAName", 9, true},
+		{"everyLineHasAName", 10, true},
+		{"everyLineHasAName", 11, false},
+		{"everyLineIsReferenced", 9, false},
+	}
+
+	for _, tt := range tests {
+		if got := s.Contains(tt.rule, tt.line); got != tt.want {
+			t.Errorf("Contains(%q, %d) = %t, want %t", tt.rule, tt.line, got, tt.want)
+		}
+	}
+}